@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"weboffice/internal/config"
+	"weboffice/internal/database"
+	"weboffice/internal/gc"
+	"weboffice/internal/storage"
+)
+
+// runGC 处理 gc 子命令：weboffice gc [-dry-run] [-grace 24h]
+func runGC(cfg *config.AppConfig, args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", cfg.GC.DryRun, "只列出将被删除的内容，不实际删除")
+	grace := fs.Duration("grace", cfg.GC.Grace, "宽限期，未被引用的内容超过该时长才会删除")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := database.InitDB(cfg.DB); err != nil {
+		return err
+	}
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	report, err := gc.NewCollector(database.DB, store, *grace).Run(*dryRun)
+	if err != nil {
+		return err
+	}
+	log.Printf("垃圾回收完成: %s", report)
+	return nil
+}
@@ -1,35 +1,103 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"weboffice/internal/config"
 	"weboffice/internal/database"
+	"weboffice/internal/gc"
 	"weboffice/internal/handlers"
+	"weboffice/internal/middleware"
+	"weboffice/internal/retention"
 	"weboffice/internal/routes"
+	"weboffice/internal/scanner"
 	"weboffice/internal/storage"
 )
 
 func main() {
-	// 加载配置
-	cfg := config.LoadConfig()
+	// 加载配置：默认值 → YAML 文件 → 环境变量 → 命令行参数
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Configuration failed: %v", err)
+	}
+
+	// 子命令：weboffice [flags] migrate up|down|status、weboffice [flags] gc [-dry-run] [-grace 24h]
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := runMigrate(cfg, args[1:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+		case "gc":
+			if err := runGC(cfg, args[1:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+				log.Fatalf("Garbage collection failed: %v", err)
+			}
+		default:
+			log.Fatalf("Unknown command: %s", args[0])
+		}
+		return
+	}
 
-	// 初始化存储系统（正确方式）
-	fileStorage := storage.NewStorage(cfg.StoragePath)
+	// 初始化存储系统（按配置选择驱动）
+	fileStorage, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Storage initialization failed: %v", err)
+	}
 	handlers.InitFileStorage(fileStorage) // 传递存储实例而非配置
+	handlers.InitConfig(cfg)
+
+	// 初始化病毒扫描（未启用时不扫描）
+	contentScanner, err := scanner.New(cfg.Scanner)
+	if err != nil {
+		log.Fatalf("Scanner initialization failed: %v", err)
+	}
+	if contentScanner != nil {
+		handlers.InitScanner(contentScanner)
+	}
+	// 启动时检查扫描服务：默认不可用即退出；配置 fail_open 时上传本就放行，只记录告警
+	if pinger, ok := contentScanner.(scanner.Pinger); ok {
+		if err := pinger.Ping(context.Background()); err != nil {
+			if !cfg.Scanner.FailOpen {
+				log.Fatalf("Scanner health check failed: %v", err)
+			}
+			log.Printf("Scanner health check failed, continuing because fail_open is set: %v", err)
+		}
+	}
 
 	// 初始化数据库
 	if err := database.InitDB(cfg.DB); err != nil {
 		log.Fatalf("Database initialization failed: %v", err)
 	}
 
-	// 初始化测试数据
-	if err := database.InitTestData(); err != nil {
-		log.Fatalf("Test data initialization failed: %v", err)
+	// 初始化测试数据（仅在显式开启 seed_test_data 时写入）
+	if cfg.SeedTestData {
+		if err := database.InitTestData(fileStorage); err != nil {
+			log.Fatalf("Test data initialization failed: %v", err)
+		}
+	}
+
+	// 定期清理过期的上传会话
+	handlers.StartUploadSessionJanitor(cfg.UploadSessionTTL)
+
+	// 定期清理不再被引用的版本内容与 Blob
+	if cfg.GC.Interval > 0 {
+		gc.NewCollector(database.DB, fileStorage, cfg.GC.Grace).Start(cfg.GC.Interval, cfg.GC.DryRun)
+	}
+
+	// 定期按保留策略清理历史版本
+	if cfg.Retention.Interval > 0 {
+		retention.NewWorker(database.DB, *cfg.Retention.Default).Start(cfg.Retention.Interval)
 	}
 
 	// 创建Gin实例
@@ -38,7 +106,11 @@ func main() {
 	configureLogger(r)
 
 	// 注册路由
-	routes.RegisterRoutes(r)
+	resolver, err := middleware.NewIdentityResolver(cfg, database.DB)
+	if err != nil {
+		log.Fatalf("Identity resolver initialization failed: %v", err)
+	}
+	routes.RegisterRoutes(r, cfg, resolver)
 
 	// 启动服务
 	log.Printf("Starting server on :%d", cfg.ServerPort)
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"weboffice/internal/config"
+	"weboffice/internal/database"
+	"weboffice/internal/migrations"
+	"weboffice/internal/storage"
+)
+
+const migrateUsage = `用法: weboffice migrate <up [N] | down [N] | status>
+  up [N]    执行未执行的迁移，N 为执行个数，缺省执行全部
+  down [N]  回滚最近执行的迁移，N 为回滚个数，缺省为 1
+  status    列出全部迁移及其执行状态`
+
+// runMigrate 处理 migrate 子命令
+func runMigrate(cfg *config.AppConfig, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少迁移操作\n%s", migrateUsage)
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("无效的迁移个数: %s", args[1])
+		}
+		steps = n
+	}
+
+	db, err := database.Connect(cfg.DB)
+	if err != nil {
+		return err
+	}
+	// 数据迁移（如附件内容搬移）需要访问存储后端
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	runner := migrations.NewRunner(db, store)
+
+	switch args[0] {
+	case "up":
+		done, err := runner.Up(steps)
+		for _, m := range done {
+			log.Printf("已执行迁移 %04d_%s", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			log.Printf("数据库结构已是最新版本")
+		}
+		return err
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		done, err := runner.Down(steps)
+		for _, m := range done {
+			log.Printf("已回滚迁移 %04d_%s", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := runner.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + time.Unix(s.AppliedAt, 0).Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-32s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的迁移操作: %s\n%s", args[0], migrateUsage)
+	}
+}
@@ -1,45 +1,230 @@
 package config
 
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 type DBConfig struct {
-	User     string
-	Password string
-	Host     string
-	Port     int
-	Name     string
+	Driver   string `yaml:"driver"` // 数据库驱动：mysql、postgres、sqlite
+	DSN      string `yaml:"dsn"`    // 完整连接串，设置后忽略下列连接参数
+	Path     string `yaml:"path"`   // SQLite 数据库文件路径
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+}
+
+// S3Config S3 兼容对象存储配置（AWS S3、MinIO 等）
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"` // 服务地址，如 localhost:9000
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"` // 对象键前缀，如 weboffice/
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	UseSSL    bool   `yaml:"use_ssl"`
+	PathStyle bool   `yaml:"path_style"` // 使用路径风格访问存储桶（MinIO 需开启）
+	PartSize  uint64 `yaml:"part_size"`  // multipart 分片大小（字节），0 表示使用默认值
+}
+
+// CallbackAuthConfig WebOffice 回调签名校验配置
+type CallbackAuthConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Apps        map[string]string `yaml:"apps"`          // 应用ID → 应用密钥
+	MaxSkew     time.Duration     `yaml:"max_skew"`      // 请求时间戳允许的最大偏差，超出视为重放
+	MaxBodySize int64             `yaml:"max_body_size"` // 请求体上限（字节），签名校验前超出即拒绝
+}
+
+// FileTypePolicy 某一类文件（文字、表格、演示等）的上传策略
+type FileTypePolicy struct {
+	Extensions        []string `yaml:"extensions"`         // 允许的扩展名（不带点）
+	BlockedExtensions []string `yaml:"blocked_extensions"` // 即使在 Extensions 中也拒绝的扩展名，如启用宏的 docm/xlsm/pptm
+	MaxSize           int64    `yaml:"max_size"`           // 单个文件大小上限（字节），0 表示不限制
+}
+
+// UnmarshalYAML 兼容旧格式：类别直接写为扩展名列表
+func (p *FileTypePolicy) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		p.BlockedExtensions, p.MaxSize = nil, 0
+		return node.Decode(&p.Extensions)
+	}
+	type plain FileTypePolicy
+	return node.Decode((*plain)(p))
+}
+
+// FileTypePolicies 类别 → 上传策略
+type FileTypePolicies map[string]*FileTypePolicy
+
+// UnmarshalYAML 配置文件中出现 allowed_file_types 时整体替换默认策略，而不是按类别合并
+func (m *FileTypePolicies) UnmarshalYAML(node *yaml.Node) error {
+	policies := make(map[string]*FileTypePolicy)
+	if err := node.Decode(&policies); err != nil {
+		return err
+	}
+	*m = policies
+	return nil
+}
+
+// 主动内容处理策略
+const (
+	ActionAllow      = "allow"      // 放行，仅在版本记录中登记
+	ActionStrip      = "strip"      // 移除后放行，仅支持 OOXML 中的宏，其余情况按 quarantine 处理；默认禁止的 docm/xlsm/pptm 等须先解除禁止
+	ActionQuarantine = "quarantine" // 隔离待审核，不生成新版本
+	ActionReject     = "reject"     // 拒绝上传
+)
+
+// ActiveContentConfig 上传文件中主动内容的处理策略
+type ActiveContentConfig struct {
+	Macros          string `yaml:"macros"`           // VBA 宏：allow、strip、quarantine、reject
+	EmbeddedObjects string `yaml:"embedded_objects"` // OLE 嵌入对象、ActiveX：allow、quarantine、reject
+	ExternalLinks   string `yaml:"external_links"`   // 外部模板、数据源链接：allow、quarantine、reject
+}
+
+// ScannerConfig 上传内容病毒扫描配置
+type ScannerConfig struct {
+	Driver   string        `yaml:"driver"`    // 扫描驱动：none（默认，不扫描）、clamd
+	Network  string        `yaml:"network"`   // clamd 连接方式：tcp、unix
+	Address  string        `yaml:"address"`   // clamd 地址，如 127.0.0.1:3310 或 /var/run/clamav/clamd.ctl
+	Timeout  time.Duration `yaml:"timeout"`   // 单次读写超时
+	FailOpen bool          `yaml:"fail_open"` // 扫描服务不可用时放行（默认拒绝上传）
+}
+
+// GCConfig 存储垃圾回收配置
+type GCConfig struct {
+	Interval time.Duration `yaml:"interval"` // 定期执行间隔，0 表示不自动执行
+	Grace    time.Duration `yaml:"grace"`    // 宽限期：未被引用的内容超过该时长才会删除
+	DryRun   bool          `yaml:"dry_run"`  // 只记录将被删除的内容，不实际删除
+}
+
+// RetentionPolicy 版本保留策略：满足任一规则的版本即被保留，当前版本与固定的版本始终保留；
+// 各项均为 0 时不清理任何版本
+type RetentionPolicy struct {
+	KeepLast   int `yaml:"keep_last" json:"keep_last"`     // 保留最近 N 个版本
+	KeepDays   int `yaml:"keep_days" json:"keep_days"`     // 保留最近 D 天内的全部版本
+	KeepDaily  int `yaml:"keep_daily" json:"keep_daily"`   // 在此之前的 N 天内，每天保留最后一个版本
+	KeepWeekly int `yaml:"keep_weekly" json:"keep_weekly"` // 再往前的 N 周内，每周保留最后一个版本
+}
+
+// IsZero 策略未设置任何保留规则
+func (p RetentionPolicy) IsZero() bool {
+	return p == RetentionPolicy{}
+}
+
+// Validate 检查策略各项均不为负数
+func (p RetentionPolicy) Validate() error {
+	if p.KeepLast < 0 || p.KeepDays < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 {
+		return errors.New("保留策略各项不能为负数")
+	}
+	return nil
+}
+
+// RetentionConfig 版本保留配置，可为单个文件设置覆盖默认策略的保留策略
+type RetentionConfig struct {
+	Interval time.Duration    `yaml:"interval"` // 后台清理间隔，0 表示不自动清理
+	Default  *RetentionPolicy `yaml:"default"`  // 未单独设置策略的文件使用的保留策略
+}
+
+// IdentityConfig 用户令牌解析配置
+type IdentityConfig struct {
+	Resolver     string            `yaml:"resolver"`      // 解析器：db（默认，查询 user_tokens 表）、static
+	StaticTokens map[string]string `yaml:"static_tokens"` // static 解析器使用的令牌 → 用户ID 映射
 }
 
 type AppConfig struct {
-	DB               *DBConfig
-	StorageURL       string // 存储服务基础地址
-	UploadURL        string // 上传服务端点
-	ServerPort       int
-	StoragePath      string              // 新增本地存储路径配置
-	AllowedFileTypes map[string][]string `yaml:"allowed_file_types"`
+	DB               *DBConfig            `yaml:"db"`
+	StorageURL       string               `yaml:"storage_url"` // 已弃用：附件链接改由本服务签发，保留该键以兼容旧配置文件
+	ServerPort       int                  `yaml:"server_port"`
+	BaseURL          string               `yaml:"base_url"`           // 本服务对外访问地址，用于生成下载/上传链接
+	URLSignKey       string               `yaml:"url_sign_key"`       // 链接签名密钥（HMAC-SHA256）
+	URLExpiry        time.Duration        `yaml:"url_expiry"`         // 签名链接有效期
+	UploadSessionTTL time.Duration        `yaml:"upload_session_ttl"` // 上传会话有效期，超时未完成的会话及暂存内容将被清理
+	ShareTokenTTL    time.Duration        `yaml:"share_token_ttl"`    // 通过分享链接换取的 WebOffice 令牌有效期
+	StorageDriver    string               `yaml:"storage_driver"`     // 存储驱动：local（默认）、memory、s3
+	StoragePath      string               `yaml:"storage_path"`       // 新增本地存储路径配置
+	S3               *S3Config            `yaml:"s3"`                 // 对象存储配置，StorageDriver 为 s3 时生效
+	CallbackAuth     *CallbackAuthConfig  `yaml:"callback_auth"`      // /v3/3rd 回调接口签名校验
+	Identity         *IdentityConfig      `yaml:"identity"`           // 用户身份解析
+	AllowedFileTypes FileTypePolicies     `yaml:"allowed_file_types"` // 按文件类别的上传策略
+	ActiveContent    *ActiveContentConfig `yaml:"active_content"`     // 宏、嵌入对象、外部链接的处理策略
+	Scanner          *ScannerConfig       `yaml:"scanner"`            // 上传内容病毒扫描
+	GC               *GCConfig            `yaml:"gc"`                 // 未被引用的版本内容与 Blob 的垃圾回收
+	Retention        *RetentionConfig     `yaml:"retention"`          // 历史版本保留策略
+	SeedTestData     bool                 `yaml:"seed_test_data"`     // 启动时写入开发/测试用示例数据，仅限开发环境开启
 }
 
-func LoadConfig() *AppConfig {
+// Default 返回内置默认配置，不包含任何密钥，密钥须由配置文件或环境变量提供
+func Default() *AppConfig {
 	return &AppConfig{
 		DB: &DBConfig{
-			User:     "webuser",
-			Password: "Aa@123456",
-			Host:     "localhost",
-			Port:     3306,
-			Name:     "weboffice",
-		},
-		StorageURL:  "http://storage.example.com", // 新增配置项
-		UploadURL:   "http://upload.example.com",  // 新增配置项
-		ServerPort:  8080,
-		StoragePath: "./storage", // 本地存储根目录
-		AllowedFileTypes: map[string][]string{
+			Driver: "mysql",
+			Path:   "./weboffice.db",
+			Host:   "localhost",
+			Port:   3306,
+			Name:   "weboffice",
+			User:   "webuser",
+		},
+		ServerPort:       8080,
+		BaseURL:          "http://localhost:8080",
+		URLExpiry:        10 * time.Minute,
+		UploadSessionTTL: 30 * time.Minute,
+		ShareTokenTTL:    2 * time.Hour,
+		StorageDriver:    "local",
+		StoragePath:      "./storage", // 本地存储根目录
+		S3: &S3Config{
+			Region:    "us-east-1",
+			Bucket:    "weboffice",
+			PathStyle: true,
+			PartSize:  16 << 20,
+		},
+		CallbackAuth: &CallbackAuthConfig{
+			Enabled:     true,
+			MaxSkew:     5 * time.Minute,
+			MaxBodySize: 256 << 20,
+		},
+		Identity: &IdentityConfig{
+			Resolver: "db",
+		},
+		ActiveContent: &ActiveContentConfig{
+			Macros:          ActionAllow,
+			EmbeddedObjects: ActionAllow,
+			ExternalLinks:   ActionAllow,
+		},
+		Scanner: &ScannerConfig{
+			Driver:  "none",
+			Network: "tcp",
+			Timeout: 30 * time.Second,
+		},
+		GC: &GCConfig{
+			Interval: 24 * time.Hour,
+			Grace:    24 * time.Hour,
+		},
+		Retention: &RetentionConfig{
+			Interval: time.Hour,
+			Default:  &RetentionPolicy{KeepLast: 5},
+		},
+		AllowedFileTypes: FileTypePolicies{
 			"document": {
-				"doc", "dot", "wps", "wpt", "docx", "dotx", "docm", "dotm",
-				"rtf", "txt", "xml", "mhtml", "mht", "html", "htm", "uof", "uot3",
+				Extensions: []string{
+					"doc", "dot", "wps", "wpt", "docx", "dotx", "docm", "dotm",
+					"rtf", "txt", "xml", "mhtml", "mht", "html", "htm", "uof", "uot3",
+				},
+				BlockedExtensions: []string{"docm", "dotm"},
 			},
 			"spreadsheet": {
-				"xls", "xlt", "et", "xlsx", "xltx", "csv", "xlsm", "xltm", "ett",
+				Extensions: []string{
+					"xls", "xlt", "et", "xlsx", "xltx", "csv", "xlsm", "xltm", "ett",
+				},
+				BlockedExtensions: []string{"xlsm", "xltm"},
 			},
 			"presentation": {
-				"ppt", "pptx", "pptm", "ppsx", "ppsm", "pps", "potx", "potm", "dpt", "dps", "pot",
+				Extensions: []string{
+					"ppt", "pptx", "pptm", "ppsx", "ppsm", "pps", "potx", "potm", "dpt", "dps", "pot",
+				},
+				BlockedExtensions: []string{"pptm", "ppsm", "potm"},
 			},
 		},
 	}
@@ -0,0 +1,344 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultConfigFile 未指定配置文件时尝试加载的路径，不存在时忽略
+	DefaultConfigFile = "config.yaml"
+	// EnvPrefix 环境变量前缀，变量名由 yaml 键路径大写并以下划线连接，如 WEBOFFICE_DB_PASSWORD
+	EnvPrefix = "WEBOFFICE_"
+)
+
+// Load 按 默认值 → YAML 文件 → 环境变量 → 命令行参数 的顺序合并配置并校验，
+// 返回配置及解析命令行参数后剩余的参数（子命令）
+func Load(args []string) (*AppConfig, []string, error) {
+	fs := flag.NewFlagSet("weboffice", flag.ContinueOnError)
+	configFile := fs.String("config", "", "配置文件路径，默认 ./"+DefaultConfigFile+"，也可通过 "+EnvPrefix+"CONFIG 指定")
+	port := fs.Int("port", 0, "监听端口")
+	baseURL := fs.String("base-url", "", "本服务对外访问地址")
+	dbDriver := fs.String("db-driver", "", "数据库驱动：mysql、postgres、sqlite")
+	dbDSN := fs.String("db-dsn", "", "数据库连接串")
+	storageDriver := fs.String("storage-driver", "", "存储驱动：local、memory、s3")
+	storagePath := fs.String("storage-path", "", "本地存储根目录")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+
+	path, explicit := *configFile, true
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		path, explicit = DefaultConfigFile, false
+	}
+	if err := loadFile(cfg, path, explicit); err != nil {
+		return nil, nil, err
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), EnvPrefix); err != nil {
+		return nil, nil, err
+	}
+
+	// 只覆盖显式传入的命令行参数
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.ServerPort = *port
+		case "base-url":
+			cfg.BaseURL = *baseURL
+		case "db-driver":
+			cfg.DB.Driver = *dbDriver
+		case "db-dsn":
+			cfg.DB.DSN = *dbDSN
+		case "storage-driver":
+			cfg.StorageDriver = *storageDriver
+		case "storage-path":
+			cfg.StoragePath = *storagePath
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile 将 YAML 文件合并到 cfg，未知键视为错误；explicit 为 false 时文件不存在不报错
+func loadFile(cfg *AppConfig, path string, explicit bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("打开配置文件失败: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv 以 yaml 标签推导环境变量名，覆盖对应字段；
+// map[string]string 字段格式为 key1=value1,key2=value2，其余 map 字段不支持环境变量
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + strings.ToUpper(tag)
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			if err := applyEnv(field.Elem(), name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("环境变量 %s 无效: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Map:
+		if field.Type().Elem().Kind() != reflect.String {
+			return errors.New("该配置项不支持通过环境变量设置")
+		}
+		m := reflect.MakeMap(field.Type())
+		for _, pair := range strings.Split(raw, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("应为 key=value 格式: %q", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), reflect.ValueOf(strings.TrimSpace(value)))
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("不支持的配置类型 %s", field.Type())
+	}
+	return nil
+}
+
+// Validate 校验配置完整性，一次性列出全部问题
+func (c *AppConfig) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.ServerPort <= 0 || c.ServerPort > 65535 {
+		add("server_port 超出范围: %d", c.ServerPort)
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("base_url 必须是 http(s) 绝对地址: %q", c.BaseURL)
+	}
+	if c.URLSignKey == "" {
+		add("url_sign_key 未设置（可通过 %sURL_SIGN_KEY 提供）", EnvPrefix)
+	}
+	if c.URLExpiry <= 0 {
+		add("url_expiry 必须大于 0")
+	}
+	if c.UploadSessionTTL <= 0 {
+		add("upload_session_ttl 必须大于 0")
+	}
+	if c.ShareTokenTTL <= 0 {
+		add("share_token_ttl 必须大于 0")
+	}
+
+	switch c.DB.Driver {
+	case "sqlite":
+		if c.DB.DSN == "" && c.DB.Path == "" {
+			add("db.path 或 db.dsn 未设置")
+		}
+	case "mysql", "postgres":
+		if c.DB.DSN == "" && (c.DB.Host == "" || c.DB.Name == "" || c.DB.User == "") {
+			add("db.dsn 或 db.host/db.name/db.user 未设置")
+		}
+	default:
+		add("不支持的数据库驱动 db.driver: %q", c.DB.Driver)
+	}
+
+	switch c.StorageDriver {
+	case "local":
+		if c.StoragePath == "" {
+			add("storage_path 未设置")
+		}
+	case "memory":
+	case "s3":
+		if c.S3.Endpoint == "" || c.S3.Bucket == "" {
+			add("s3.endpoint 或 s3.bucket 未设置")
+		}
+		if c.S3.AccessKey == "" || c.S3.SecretKey == "" {
+			add("s3.access_key 或 s3.secret_key 未设置")
+		}
+	default:
+		add("不支持的存储驱动 storage_driver: %q", c.StorageDriver)
+	}
+
+	if c.CallbackAuth.Enabled {
+		if len(c.CallbackAuth.Apps) == 0 {
+			add("callback_auth 已启用但未配置 apps")
+		}
+		for appID, secret := range c.CallbackAuth.Apps {
+			if secret == "" {
+				add("callback_auth.apps[%s] 密钥为空", appID)
+			}
+		}
+		if c.CallbackAuth.MaxSkew <= 0 {
+			add("callback_auth.max_skew 必须大于 0")
+		}
+		if c.CallbackAuth.MaxBodySize <= 0 {
+			add("callback_auth.max_body_size 必须大于 0")
+		}
+	}
+
+	switch c.Identity.Resolver {
+	case "", "db":
+	case "static":
+		if len(c.Identity.StaticTokens) == 0 {
+			add("identity.resolver 为 static 但未配置 static_tokens")
+		}
+	default:
+		add("不支持的身份解析器 identity.resolver: %q", c.Identity.Resolver)
+	}
+
+	switch c.ActiveContent.Macros {
+	case ActionAllow, ActionStrip, ActionQuarantine, ActionReject:
+	default:
+		add("不支持的宏处理策略 active_content.macros: %q", c.ActiveContent.Macros)
+	}
+	for key, action := range map[string]string{
+		"embedded_objects": c.ActiveContent.EmbeddedObjects,
+		"external_links":   c.ActiveContent.ExternalLinks,
+	} {
+		switch action {
+		case ActionAllow, ActionQuarantine, ActionReject:
+		default:
+			add("不支持的处理策略 active_content.%s: %q（可选 allow、quarantine、reject）", key, action)
+		}
+	}
+
+	switch c.Scanner.Driver {
+	case "", "none":
+	case "clamd":
+		switch c.Scanner.Network {
+		case "", "tcp", "unix":
+		default:
+			add("不支持的连接方式 scanner.network: %q（可选 tcp、unix）", c.Scanner.Network)
+		}
+		if c.Scanner.Address == "" {
+			add("scanner.driver 为 clamd 但未配置 scanner.address")
+		}
+		if c.Scanner.Timeout <= 0 {
+			add("scanner.timeout 必须大于 0")
+		}
+	default:
+		add("不支持的扫描驱动 scanner.driver: %q", c.Scanner.Driver)
+	}
+
+	if c.GC.Interval < 0 {
+		add("gc.interval 不能为负数")
+	}
+	if c.GC.Grace < 0 {
+		add("gc.grace 不能为负数")
+	}
+
+	if c.Retention.Interval < 0 {
+		add("retention.interval 不能为负数")
+	}
+	if err := c.Retention.Default.Validate(); err != nil {
+		add("retention.default: %v", err)
+	}
+
+	if len(c.AllowedFileTypes) == 0 {
+		add("allowed_file_types 未配置任何文件类别")
+	}
+	seen := make(map[string]string)
+	for category, policy := range c.AllowedFileTypes {
+		if policy == nil || len(policy.Extensions) == 0 {
+			add("allowed_file_types.%s 未配置 extensions", category)
+			continue
+		}
+		if policy.MaxSize < 0 {
+			add("allowed_file_types.%s.max_size 不能为负数", category)
+		}
+		for _, ext := range policy.Extensions {
+			ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+			if other, ok := seen[ext]; ok && other != category {
+				add("扩展名 %s 同时出现在 %s 与 %s 类别中", ext, other, category)
+			}
+			seen[ext] = category
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("配置无效:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv 清除进程中已有的 WEBOFFICE_ 环境变量，测试结束后恢复
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, EnvPrefix) {
+			continue
+		}
+		os.Unsetenv(key)
+		t.Cleanup(func() { os.Setenv(key, value) })
+	}
+}
+
+// writeConfig 将 YAML 写入临时目录并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const minimalYAML = `
+url_sign_key: yaml-key
+callback_auth:
+  apps:
+    app1: secret1
+`
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+server_port: 9000
+base_url: https://yaml.example.com
+url_sign_key: yaml-key
+storage_driver: memory
+db:
+  driver: sqlite
+  path: /data/yaml.db
+callback_auth:
+  max_skew: 1m
+  apps:
+    app1: yaml-secret
+retention:
+  interval: 2h
+`)
+	t.Setenv("WEBOFFICE_SERVER_PORT", "9100")
+	t.Setenv("WEBOFFICE_URL_SIGN_KEY", "env-key")
+	t.Setenv("WEBOFFICE_DB_PATH", "/data/env.db")
+	t.Setenv("WEBOFFICE_CALLBACK_AUTH_MAX_SKEW", "2m")
+	t.Setenv("WEBOFFICE_CALLBACK_AUTH_APPS", "app1=env-secret, app2=second")
+	t.Setenv("WEBOFFICE_SCANNER_FAIL_OPEN", "true")
+
+	cfg, rest, err := Load([]string{"-config", path, "-port", "9200", "-db-dsn", "file:flag.db", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		// 命令行参数优先于环境变量
+		{"server_port", cfg.ServerPort, 9200},
+		{"db.dsn", cfg.DB.DSN, "file:flag.db"},
+		// 环境变量优先于 YAML
+		{"url_sign_key", cfg.URLSignKey, "env-key"},
+		{"db.path", cfg.DB.Path, "/data/env.db"},
+		{"callback_auth.max_skew", cfg.CallbackAuth.MaxSkew, 2 * time.Minute},
+		{"callback_auth.apps", cfg.CallbackAuth.Apps, map[string]string{"app1": "env-secret", "app2": "second"}},
+		{"scanner.fail_open", cfg.Scanner.FailOpen, true},
+		// YAML 优先于默认值
+		{"base_url", cfg.BaseURL, "https://yaml.example.com"},
+		{"storage_driver", cfg.StorageDriver, "memory"},
+		{"db.driver", cfg.DB.Driver, "sqlite"},
+		{"retention.interval", cfg.Retention.Interval, 2 * time.Hour},
+		// 未设置的项保持默认值，包括 YAML 中只设置了部分字段的小节
+		{"url_expiry", cfg.URLExpiry, 10 * time.Minute},
+		{"callback_auth.enabled", cfg.CallbackAuth.Enabled, true},
+		{"callback_auth.max_body_size", cfg.CallbackAuth.MaxBodySize, int64(256 << 20)},
+		{"retention.default", *cfg.Retention.Default, RetentionPolicy{KeepLast: 5}},
+		{"db.host", cfg.DB.Host, "localhost"},
+		{"scanner.timeout", cfg.Scanner.Timeout, 30 * time.Second},
+		// 解析参数后剩余的子命令
+		{"args", rest, []string{"migrate", "up"}},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s = %v，期望 %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("WEBOFFICE_CONFIG", writeConfig(t, minimalYAML+"server_port: 9300\n"))
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ServerPort != 9300 {
+		t.Errorf("server_port = %d，期望读取 %sCONFIG 指定的文件", cfg.ServerPort, EnvPrefix)
+	}
+}
+
+func TestLoadDefaultFileOptional(t *testing.T) {
+	clearEnv(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// 当前目录没有 config.yaml 时只使用默认值与环境变量
+	t.Setenv("WEBOFFICE_URL_SIGN_KEY", "env-key")
+	t.Setenv("WEBOFFICE_CALLBACK_AUTH_ENABLED", "false")
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ServerPort != 8080 || cfg.URLSignKey != "env-key" {
+		t.Errorf("配置 %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "unknown top-level key",
+			yaml:    minimalYAML + "server_prot: 9000\n",
+			wantErr: "server_prot",
+		},
+		{
+			name:    "unknown nested key",
+			yaml:    minimalYAML + "scanner:\n  adress: 127.0.0.1:3310\n",
+			wantErr: "adress",
+		},
+		{
+			name:    "wrong value type",
+			yaml:    minimalYAML + "server_port: eighty\n",
+			wantErr: "解析配置文件",
+		},
+		{
+			name:    "invalid environment variable",
+			yaml:    minimalYAML,
+			env:     map[string]string{"WEBOFFICE_URL_EXPIRY": "ten minutes"},
+			wantErr: "环境变量 WEBOFFICE_URL_EXPIRY 无效",
+		},
+		{
+			name:    "environment map without separator",
+			yaml:    minimalYAML,
+			env:     map[string]string{"WEBOFFICE_CALLBACK_AUTH_APPS": "app1"},
+			wantErr: "key=value",
+		},
+		{
+			name:    "unsupported map from environment",
+			yaml:    minimalYAML,
+			env:     map[string]string{"WEBOFFICE_ALLOWED_FILE_TYPES": "document=docx"},
+			wantErr: "不支持通过环境变量设置",
+		},
+		{
+			name:    "unknown flag",
+			yaml:    minimalYAML,
+			args:    []string{"-no-such-flag"},
+			wantErr: "no-such-flag",
+		},
+		{
+			name:    "validation runs after flags",
+			yaml:    minimalYAML,
+			args:    []string{"-port", "70000"},
+			wantErr: "server_port 超出范围",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			args := append([]string{"-config", writeConfig(t, tc.yaml)}, tc.args...)
+			_, _, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Load 返回错误 %v，期望包含 %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadMissingExplicitFile(t *testing.T) {
+	clearEnv(t)
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if _, _, err := Load([]string{"-config", missing}); err == nil {
+		t.Error("显式指定的配置文件不存在时应返回错误")
+	}
+	t.Setenv("WEBOFFICE_CONFIG", missing)
+	if _, _, err := Load(nil); err == nil {
+		t.Errorf("%sCONFIG 指定的配置文件不存在时应返回错误", EnvPrefix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *AppConfig {
+		cfg := Default()
+		cfg.URLSignKey = "key"
+		cfg.CallbackAuth.Apps = map[string]string{"app1": "secret1"}
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *AppConfig)
+		wantErr []string // 为空表示校验通过
+	}{
+		{name: "defaults with required keys", modify: func(cfg *AppConfig) {}},
+		{
+			name:    "url_sign_key is required",
+			modify:  func(cfg *AppConfig) { cfg.URLSignKey = "" },
+			wantErr: []string{"url_sign_key 未设置"},
+		},
+		{
+			name:    "callback auth requires apps",
+			modify:  func(cfg *AppConfig) { cfg.CallbackAuth.Apps = nil },
+			wantErr: []string{"callback_auth 已启用但未配置 apps"},
+		},
+		{
+			name:    "callback auth rejects empty secrets",
+			modify:  func(cfg *AppConfig) { cfg.CallbackAuth.Apps["app2"] = "" },
+			wantErr: []string{"callback_auth.apps[app2] 密钥为空"},
+		},
+		{
+			name: "callback auth limits must be positive",
+			modify: func(cfg *AppConfig) {
+				cfg.CallbackAuth.MaxSkew = 0
+				cfg.CallbackAuth.MaxBodySize = 0
+			},
+			wantErr: []string{"callback_auth.max_skew 必须大于 0", "callback_auth.max_body_size 必须大于 0"},
+		},
+		{
+			name: "disabled callback auth needs no apps",
+			modify: func(cfg *AppConfig) {
+				cfg.CallbackAuth.Enabled = false
+				cfg.CallbackAuth.Apps = nil
+				cfg.CallbackAuth.MaxBodySize = 0
+			},
+		},
+		{
+			// 一次列出全部问题
+			name: "all problems are reported",
+			modify: func(cfg *AppConfig) {
+				cfg.URLSignKey = ""
+				cfg.CallbackAuth.Apps = nil
+				cfg.DB.Driver = "oracle"
+				cfg.ActiveContent.EmbeddedObjects = ActionStrip
+			},
+			wantErr: []string{"url_sign_key 未设置", "未配置 apps", "不支持的数据库驱动", "active_content.embedded_objects"},
+		},
+		{
+			name: "extension in two categories",
+			modify: func(cfg *AppConfig) {
+				cfg.AllowedFileTypes["spreadsheet"].Extensions = append(cfg.AllowedFileTypes["spreadsheet"].Extensions, ".DOCX")
+			},
+			wantErr: []string{"扩展名 docx 同时出现在"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid()
+			tc.modify(cfg)
+			err := cfg.Validate()
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate 应返回错误，期望包含 %q", tc.wantErr)
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("错误 %q 未包含 %q", err, want)
+				}
+			}
+		})
+	}
+}
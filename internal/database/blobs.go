@@ -0,0 +1,51 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weboffice/internal/models"
+)
+
+// AcquireBlob 在事务内登记对内容 sum 的一次引用，返回该内容是否已存在；
+// 已存在时调用方无需再写入存储，否则须在同一事务提交前写入对象 sum
+func AcquireBlob(tx *gorm.DB, sum string, size int64) (bool, error) {
+	now := time.Now().Unix()
+	// 插入与递增之间该行可能被垃圾回收删除，此时重新插入
+	for attempt := 0; attempt < 3; attempt++ {
+		blob := models.Blob{Sha256: sum, Size: size, RefCount: 1, CreatedAt: now, UpdatedAt: now}
+		// 并发写入相同内容时，冲突的插入会等待先插入的事务结束
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&blob)
+		if result.Error != nil {
+			return false, fmt.Errorf("登记内容引用失败: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return false, nil
+		}
+		result = tx.Model(&models.Blob{}).Where("sha256 = ?", sum).Updates(map[string]interface{}{
+			"ref_count":  gorm.Expr("ref_count + 1"),
+			"updated_at": now,
+		})
+		if result.Error != nil {
+			return false, fmt.Errorf("登记内容引用失败: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("登记内容引用失败: 内容 %s 并发变更", sum)
+}
+
+// ReleaseBlob 在事务内释放对内容 sum 的一次引用，引用计数归零的内容由垃圾回收清理
+func ReleaseBlob(tx *gorm.DB, sum string) error {
+	if err := tx.Model(&models.Blob{}).Where("sha256 = ? AND ref_count > 0", sum).Updates(map[string]interface{}{
+		"ref_count":  gorm.Expr("ref_count - 1"),
+		"updated_at": time.Now().Unix(),
+	}).Error; err != nil {
+		return fmt.Errorf("释放内容引用失败: %w", err)
+	}
+	return nil
+}
@@ -1,104 +1,125 @@
 package database
 
 import (
-	"errors"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"time"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"weboffice/internal/config"
+	"weboffice/internal/migrations"
 	"weboffice/internal/models"
+	"weboffice/internal/utils"
 
 	"bytes"                      // 新增
-	"os"                         // 新增
 	"weboffice/internal/storage" // 新增
 )
 
 var DB *gorm.DB
 
-// InitDB函数用于初始化数据库连接
+// InitDB函数用于初始化数据库连接，并确认数据库结构已迁移到最新版本
 func InitDB(cfg *config.DBConfig) error {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+	db, err := Connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := migrations.NewRunner(db, nil).EnsureCurrent(); err != nil {
+		// 拒绝在未迁移的数据库上启动，避免运行时才暴露表结构不一致
+		log.Printf("数据库结构检查失败: %v", err)
+		return err
+	}
+
+	DB = db
+	return nil
+}
+
+// Connect 按 cfg.Driver 连接 MySQL、PostgreSQL 或 SQLite，不检查表结构
+func Connect(cfg *config.DBConfig) (*gorm.DB, error) {
+	dialector, err := openDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		PrepareStmt: true,
 	})
 
 	if err != nil {
 		// 在数据库连接失败时使用log包记录错误信息
 		log.Printf("数据库连接失败: %v", err)
-		return fmt.Errorf("database connection failed: %w", err)
+		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
 
+	if err := configurePool(db); err != nil {
+		return nil, fmt.Errorf("database connection failed: %w", err)
+	}
 	sqlDB, _ := db.DB()
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if err := autoMigrate(db); err != nil {
-		// 在数据库迁移失败时使用log包记录错误信息
-		log.Printf("数据库迁移失败: %v", err)
-		return fmt.Errorf("database migration failed: %w", err)
-	}
-
-	DB = db
-	return nil
+	return db, nil
 }
 
-// autoMigrate函数用于自动执行数据库迁移
-func autoMigrate(db *gorm.DB) error {
-	// 设置优化后的表选项
-	return db.Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC").AutoMigrate(
-		&models.File{},
-		&models.FileVersion{},
-		&models.User{},
-		&models.Watermark{},
-		&models.Attachment{},
-	)
-}
+// InitTestData 写入开发/测试用示例数据，仅在配置 seed_test_data 时调用。
+// 示例用户的令牌每次随机生成并写入日志，不使用固定令牌
+func InitTestData(store storage.Storage) error {
+	token, err := newTestToken()
+	if err != nil {
+		return err
+	}
 
-func InitTestData() error {
 	// 先执行数据库初始化
-	if err := initDatabaseData(); err != nil {
+	if err := initDatabaseData(token); err != nil {
 		return err
 	}
+	log.Printf("已写入测试数据，用户 user1 的令牌: %s", token)
 
 	// 再执行存储初始化
-	return initFileStorageData()
+	return initFileStorageData(store)
 }
 
 // 数据库数据初始化（保持原有事务逻辑）
-func initDatabaseData() error {
+func initDatabaseData(testToken string) error {
 	return DB.Transaction(func(tx *gorm.DB) error {
 		// 清理旧测试数据
 		if err := tx.Exec("DELETE FROM users WHERE id =?", "user1").Error; err != nil {
 			log.Printf("清理用户数据失败: %v", err)
 			return fmt.Errorf("清理用户数据失败: %w", err)
 		}
+		if err := tx.Exec("DELETE FROM user_tokens WHERE user_id =?", "user1").Error; err != nil {
+			return fmt.Errorf("清理用户令牌失败: %w", err)
+		}
 
 		// 清理所有可能残留的测试数据（通配符匹配）
 		if err := tx.Exec("DELETE FROM files WHERE id LIKE 'file%'").Error; err != nil {
 			return fmt.Errorf("清理文件数据失败: %w", err)
 		}
+		var sums []string
+		if err := tx.Model(&models.FileVersion{}).Where("id LIKE 'file%' AND sha256 <> ''").
+			Pluck("sha256", &sums).Error; err != nil {
+			return fmt.Errorf("清理版本数据失败: %w", err)
+		}
 		if err := tx.Exec("DELETE FROM file_versions WHERE id LIKE 'file%'").Error; err != nil {
 			return fmt.Errorf("清理版本数据失败: %w", err)
 		}
+		for _, sum := range sums {
+			if err := ReleaseBlob(tx, sum); err != nil {
+				return err
+			}
+		}
 
 		if err := tx.Exec("DELETE FROM watermarks WHERE file_id =?", "file123").Error; err != nil {
 			log.Printf("清理水印数据失败: %v", err)
 			return fmt.Errorf("清理水印数据失败: %w", err)
 		}
 
-		if err := tx.Exec("DELETE FROM attachments WHERE `key` =?", "sample_key").Error; err != nil {
-			log.Printf("清理附件数据失败: %v", err)
-			return fmt.Errorf("清理附件数据失败: %w", err)
-		}
-
 		// 初始化用户
 		user := models.User{
 			ID:        "user1",
@@ -116,6 +137,20 @@ func initDatabaseData() error {
 			return fmt.Errorf("初始化用户失败: %w", err)
 		}
 
+		// 初始化用户令牌
+		token := models.UserToken{
+			Token:      testToken,
+			UserID:     user.ID,
+			CreateTime: time.Now().Unix(),
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "token"}},
+			UpdateAll: true,
+		}).Create(&token).Error; err != nil {
+			log.Printf("初始化用户令牌失败: %v", err)
+			return fmt.Errorf("初始化用户令牌失败: %w", err)
+		}
+
 		// 初始化主文件
 		now := time.Now().Unix()
 		file := models.File{
@@ -127,6 +162,7 @@ func initDatabaseData() error {
 			ModifyTime: now,
 			CreatorID:  "user1",
 			ModifierID: "user1",
+			OwnerID:    "user1",
 		}
 		if err := tx.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
@@ -167,56 +203,88 @@ func initDatabaseData() error {
 			return fmt.Errorf("初始化水印失败: %w", err)
 		}
 
-		// 初始化附件
-		attachment := models.Attachment{
-			Key:       "sample_key",
-			Data:      []byte("sample content"),
-			CreatedAt: now,
-		}
-		if len(attachment.Data) == 0 {
-			return errors.New("附件内容不能为空")
-		}
-		if err := tx.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "`key`"}},
-			DoNothing: true,
-		}).Create(&attachment).Error; err != nil {
-			log.Printf("初始化附件失败: %v", err)
-			return fmt.Errorf("初始化附件失败: %w", err)
-		}
-
 		return nil
 	})
 }
 
-// 文件存储初始化（新增函数）
-func initFileStorageData() error {
-	cfg := config.LoadConfig()
-
-	// 创建存储目录
-	if err := os.MkdirAll(cfg.StoragePath, 0755); err != nil {
-		log.Printf("创建存储目录失败: %v", err)
-		return fmt.Errorf("创建存储目录失败: %w", err)
+// newTestToken 生成测试用户的随机令牌
+func newTestToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成测试令牌失败: %w", err)
 	}
+	return hex.EncodeToString(buf), nil
+}
 
-	// 初始化存储实例
-	storage := storage.NewStorage(cfg.StoragePath)
-
-	// 保存测试文件
-	testContent := bytes.NewReader([]byte("测试文档内容"))
-	if err := storage.SaveFile("file123", 1, "测试文档v1.docx", testContent); err != nil {
+// 文件存储初始化（新增函数）
+func initFileStorageData(store storage.Storage) error {
+	// 保存测试文件：内容按 SHA-256 写入存储后端，并将内容清单记入版本记录
+	testName, testData := "测试文档v1.docx", []byte("测试文档内容")
+	testSha1, testMd5, testSha256 := sha1.Sum(testData), md5.Sum(testData), sha256.Sum256(testData)
+	testSum := hex.EncodeToString(testSha256[:])
+	if _, err := store.PutObject(testSum, bytes.NewReader(testData)); err != nil {
 		log.Printf("存储测试文件失败: %v", err)
 		return fmt.Errorf("存储测试文件失败: %w", err)
 	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.FileVersion{}).
+			Where("id = ? AND version = ?", "file123", 1).
+			Updates(map[string]interface{}{
+				"sha256":        testSum,
+				"storage_key":   storage.ObjectStorageKey(testSum),
+				"sha1":          hex.EncodeToString(testSha1[:]),
+				"md5":           hex.EncodeToString(testMd5[:]),
+				"content_type":  utils.ContentTypeByName(testName),
+				"original_name": testName,
+			}).Error; err != nil {
+			return err
+		}
+		_, err := AcquireBlob(tx, testSum, int64(len(testData)))
+		return err
+	})
+	if err != nil {
+		log.Printf("初始化文件版本清单失败: %v", err)
+		return fmt.Errorf("初始化文件版本清单失败: %w", err)
+	}
+
+	// 保存测试附件：内容按 SHA-256 写入存储后端，表中只记录元信息
+	sample := []byte("sample content")
+	md5Sum, sha1Sum, sha256Sum := md5.Sum(sample), sha1.Sum(sample), sha256.Sum256(sample)
+	sum := hex.EncodeToString(sha256Sum[:])
+	if _, err := store.PutObject(sum, bytes.NewReader(sample)); err != nil {
+		log.Printf("存储测试附件失败: %v", err)
+		return fmt.Errorf("存储测试附件失败: %w", err)
+	}
+	attachment := models.Attachment{
+		Key:         "sample_key",
+		Size:        int64(len(sample)),
+		Md5:         hex.EncodeToString(md5Sum[:]),
+		Sha1:        hex.EncodeToString(sha1Sum[:]),
+		ContentType: "text/plain; charset=utf-8",
+		Sha256:      sum,
+		StorageKey:  sum,
+		CreatedAt:   time.Now().Unix(),
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoNothing: true,
+		}).Create(&attachment)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		_, err := AcquireBlob(tx, sum, attachment.Size)
+		return err
+	})
+	if err != nil {
+		log.Printf("初始化附件失败: %v", err)
+		return fmt.Errorf("初始化附件失败: %w", err)
+	}
 
 	return nil
 }
 
+// Transaction 以可重复读隔离级别执行事务（SQLite 下为默认的串行化事务）
 func Transaction(fc func(tx *gorm.DB) error) error {
-	return DB.Transaction(func(tx *gorm.DB) error {
-		// 设置事务隔离级别为可重复读
-		if err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ").Error; err != nil {
-			return fmt.Errorf("设置事务隔离级别失败: %w", err)
-		}
-		return fc(tx)
-	})
+	return DB.Transaction(fc, txOptions(DB))
 }
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"weboffice/internal/config"
+	"weboffice/internal/migrations"
+	"weboffice/internal/models"
+	"weboffice/internal/storage"
+)
+
+// newSQLiteConfig 返回临时目录下的 SQLite 配置，测试无需 MySQL 服务
+func newSQLiteConfig(t *testing.T) *config.DBConfig {
+	t.Helper()
+	return &config.DBConfig{
+		Driver: DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "weboffice.db"),
+	}
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+func TestInitDBRequiresMigrations(t *testing.T) {
+	if err := InitDB(newSQLiteConfig(t)); err == nil {
+		t.Fatal("未迁移的数据库上 InitDB 应返回错误")
+	}
+}
+
+func TestInitDBSQLite(t *testing.T) {
+	cfg := newSQLiteConfig(t)
+	store := storage.NewMemoryStorage()
+
+	db, err := Connect(cfg)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { closeDB(db) })
+	if _, err := migrations.NewRunner(db, store).Up(0); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	if err := InitDB(cfg); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		closeDB(DB)
+		DB = nil
+	})
+	// 重复写入测试数据时旧令牌被替换，且不使用固定令牌
+	for i := 0; i < 2; i++ {
+		if err := InitTestData(store); err != nil {
+			t.Fatalf("InitTestData: %v", err)
+		}
+	}
+	var tokens []models.UserToken
+	if err := DB.Where("user_id = ?", "user1").Find(&tokens).Error; err != nil {
+		t.Fatalf("查询测试令牌: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].Token == "user1-token" {
+		t.Errorf("测试用户令牌为 %+v，期望一个随机令牌", tokens)
+	}
+
+	var version models.FileVersion
+	if err := DB.Where("id = ? AND version = ?", "file123", 1).First(&version).Error; err != nil {
+		t.Fatalf("查询测试文档版本: %v", err)
+	}
+	reader, err := store.GetFile(version.StorageKey)
+	if err != nil {
+		t.Fatalf("GetFile(%q): %v", version.StorageKey, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("读取测试文档内容: %v", err)
+	}
+	if string(content) != "测试文档内容" {
+		t.Errorf("测试文档内容为 %q", content)
+	}
+}
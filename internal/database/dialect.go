@@ -0,0 +1,72 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"weboffice/internal/config"
+)
+
+// 支持的数据库驱动
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// openDialector 根据配置构造对应方言的 GORM 驱动
+func openDialector(cfg *config.DBConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "", DriverMySQL:
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+				cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+		}
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+				cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name)
+		}
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		dsn := cfg.DSN
+		if dsn == "" {
+			// 开启 WAL 与忙等待，减少单机并发写入时的锁冲突
+			dsn = cfg.Path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+		}
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver)
+	}
+}
+
+// configurePool 按方言设置连接池，SQLite 只允许单个写连接
+func configurePool(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if db.Dialector.Name() == DriverSQLite {
+		sqlDB.SetMaxOpenConns(1)
+		return nil
+	}
+	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetMaxOpenConns(100)
+	return nil
+}
+
+// txOptions 返回可重复读隔离级别的事务选项；SQLite 事务本身即串行化，不支持设置隔离级别
+func txOptions(db *gorm.DB) *sql.TxOptions {
+	if db.Dialector.Name() == DriverSQLite {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+}
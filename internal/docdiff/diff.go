@@ -0,0 +1,143 @@
+package docdiff
+
+// 求最长公共子序列时动态规划表的单元数上限（去掉相同的首尾后），超过时按位置逐一比较
+const maxLCSCells = 1 << 22
+
+// compareKeyed 按位置比较：两边位置相同的单元内容不同为修改，仅一边存在为插入或删除。
+// 结果按新版本中的顺序排列，删除项排在最后
+func compareKeyed(from, to []Unit) *Result {
+	result := &Result{}
+	old := make(map[string]Unit, len(from))
+	for _, u := range from {
+		old[u.Ref] = u
+	}
+	seen := make(map[string]bool, len(to))
+	for _, u := range to {
+		seen[u.Ref] = true
+		o, ok := old[u.Ref]
+		switch {
+		case !ok:
+			result.add(Change{Op: OpInsert, Kind: u.Kind, NewRef: u.Ref, New: u.Text})
+		case o.Text != u.Text:
+			result.add(Change{Op: OpModify, Kind: u.Kind, OldRef: o.Ref, NewRef: u.Ref, Old: o.Text, New: u.Text})
+		default:
+			result.Stats.Unchanged++
+		}
+	}
+	for _, u := range from {
+		if !seen[u.Ref] {
+			result.add(Change{Op: OpDelete, Kind: u.Kind, OldRef: u.Ref, Old: u.Text})
+		}
+	}
+	return result
+}
+
+// compareSequence 按顺序比较：求文本的最长公共子序列，其余为插入或删除，
+// 相邻的一段删除与插入按顺序配对为修改
+func compareSequence(from, to []Unit) *Result {
+	result := &Result{}
+
+	// 去掉相同的首尾，缩小动态规划规模
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix].Text == to[prefix].Text {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix &&
+		from[len(from)-1-suffix].Text == to[len(to)-1-suffix].Text {
+		suffix++
+	}
+	result.Stats.Unchanged += prefix + suffix
+
+	a, b := from[prefix:len(from)-suffix], to[prefix:len(to)-suffix]
+	var deleted, inserted []Unit
+	flush := func() {
+		result.addRun(deleted, inserted)
+		deleted, inserted = deleted[:0], inserted[:0]
+	}
+
+	if len(a)*len(b) > maxLCSCells {
+		// 差异过大时不求公共子序列，按位置配对
+		for i := 0; i < len(a) || i < len(b); i++ {
+			switch {
+			case i >= len(a):
+				inserted = append(inserted, b[i])
+			case i >= len(b):
+				deleted = append(deleted, a[i])
+			case a[i].Text == b[i].Text:
+				flush()
+				result.Stats.Unchanged++
+			default:
+				deleted = append(deleted, a[i])
+				inserted = append(inserted, b[i])
+			}
+		}
+		flush()
+		return result
+	}
+
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	n, m := len(a), len(b)
+	lcs := make([]int32, (n+1)*(m+1))
+	at := func(i, j int) int32 { return lcs[i*(m+1)+j] }
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i].Text == b[j].Text:
+				lcs[i*(m+1)+j] = at(i+1, j+1) + 1
+			case at(i+1, j) >= at(i, j+1):
+				lcs[i*(m+1)+j] = at(i+1, j)
+			default:
+				lcs[i*(m+1)+j] = at(i, j+1)
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i].Text == b[j].Text:
+			flush()
+			result.Stats.Unchanged++
+			i++
+			j++
+		case j < m && (i == n || at(i, j+1) > at(i+1, j)):
+			inserted = append(inserted, b[j])
+			j++
+		default:
+			deleted = append(deleted, a[i])
+			i++
+		}
+	}
+	flush()
+	return result
+}
+
+// addRun 记录一段连续的删除与插入，按顺序配对为修改，多出的部分为删除或插入
+func (r *Result) addRun(deleted, inserted []Unit) {
+	for k := 0; k < len(deleted) || k < len(inserted); k++ {
+		switch {
+		case k >= len(deleted):
+			u := inserted[k]
+			r.add(Change{Op: OpInsert, Kind: u.Kind, NewRef: u.Ref, New: u.Text})
+		case k >= len(inserted):
+			u := deleted[k]
+			r.add(Change{Op: OpDelete, Kind: u.Kind, OldRef: u.Ref, Old: u.Text})
+		default:
+			o, u := deleted[k], inserted[k]
+			r.add(Change{Op: OpModify, Kind: u.Kind, OldRef: o.Ref, NewRef: u.Ref, Old: o.Text, New: u.Text})
+		}
+	}
+}
+
+func (r *Result) add(c Change) {
+	r.Changes = append(r.Changes, c)
+	switch c.Op {
+	case OpInsert:
+		r.Stats.Inserted++
+	case OpDelete:
+		r.Stats.Deleted++
+	case OpModify:
+		r.Stats.Modified++
+	}
+}
@@ -0,0 +1,142 @@
+// Package docdiff 提取文档的纯文本内容并比较两个版本的差异：
+// docx 按段落、xlsx 与 csv 按单元格、pptx 按幻灯片、txt 按行
+//
+// OOXML 仅支持 Transitional 命名空间；纯文本与 CSV 按 UTF-8 解析
+package docdiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// 文档格式
+const (
+	FormatDocx = "docx"
+	FormatXlsx = "xlsx"
+	FormatPptx = "pptx"
+	FormatText = "txt"
+	FormatCSV  = "csv"
+)
+
+// 比较单元
+const (
+	KindParagraph = "paragraph"
+	KindCell      = "cell"
+	KindSlide     = "slide"
+	KindLine      = "line"
+)
+
+var (
+	// ErrUnsupported 不支持比较的文件格式
+	ErrUnsupported = errors.New("不支持比较的文件格式")
+	// ErrInvalidDocument 文档结构无效，无法提取文本
+	ErrInvalidDocument = errors.New("文档结构无效")
+)
+
+// 按扩展名归类的可比较格式，含宏的格式与模板按对应的文档格式解析
+var formatsByExt = map[string]string{
+	"docx": FormatDocx, "docm": FormatDocx, "dotx": FormatDocx, "dotm": FormatDocx,
+	"xlsx": FormatXlsx, "xlsm": FormatXlsx, "xltx": FormatXlsx, "xltm": FormatXlsx,
+	"pptx": FormatPptx, "pptm": FormatPptx, "ppsx": FormatPptx, "ppsm": FormatPptx, "potx": FormatPptx, "potm": FormatPptx,
+	"txt": FormatText,
+	"csv": FormatCSV,
+}
+
+// FormatOf 按文件名扩展名返回可比较的格式，不支持时返回空串
+func FormatOf(name string) string {
+	return formatsByExt[strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))]
+}
+
+// Unit 文档中参与比较的一段文本
+type Unit struct {
+	Kind string
+	Ref  string // 位置：段落、行、幻灯片为序号，单元格为 Sheet1!A1 形式
+	Text string
+}
+
+// Document 从文件中提取的文本内容
+type Document struct {
+	Format string
+	Units  []Unit
+}
+
+// Extract 按格式从 r 中提取文本，size 为内容长度
+func Extract(r io.ReaderAt, size int64, format string) (*Document, error) {
+	var (
+		units []Unit
+		err   error
+	)
+	switch format {
+	case FormatDocx:
+		units, err = extractDocx(r, size)
+	case FormatXlsx:
+		units, err = extractXlsx(r, size)
+	case FormatPptx:
+		units, err = extractPptx(r, size)
+	case FormatText:
+		units, err = extractText(io.NewSectionReader(r, 0, size))
+	case FormatCSV:
+		units, err = extractCSV(io.NewSectionReader(r, 0, size))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupported, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Format: format, Units: units}, nil
+}
+
+// 变更类型
+const (
+	OpInsert = "insert"
+	OpDelete = "delete"
+	OpModify = "modify"
+)
+
+// Change 一处差异，Old/OldRef 为旧版本中的内容与位置，New/NewRef 为新版本中的
+type Change struct {
+	Op     string `json:"op"`
+	Kind   string `json:"kind"`
+	OldRef string `json:"old_ref,omitempty"`
+	NewRef string `json:"new_ref,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// Stats 差异统计
+type Stats struct {
+	Inserted  int `json:"inserted"`
+	Deleted   int `json:"deleted"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Result 两个版本的比较结果
+type Result struct {
+	Format  string   `json:"format"`
+	Changes []Change `json:"changes"`
+	Stats   Stats    `json:"stats"`
+}
+
+// Compare 比较同一格式的两个文档：单元格按位置对应比较，其余按顺序求最长公共子序列，
+// 相邻的删除与插入配对为修改
+func Compare(from, to *Document) (*Result, error) {
+	if from.Format != to.Format {
+		return nil, fmt.Errorf("%w: %s 与 %s 格式不同", ErrUnsupported, from.Format, to.Format)
+	}
+	var result *Result
+	switch from.Format {
+	case FormatXlsx, FormatCSV:
+		result = compareKeyed(from.Units, to.Units)
+	default:
+		result = compareSequence(from.Units, to.Units)
+	}
+	result.Format = from.Format
+	if result.Changes == nil {
+		result.Changes = []Change{}
+	}
+	return result, nil
+}
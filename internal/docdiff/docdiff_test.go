@@ -0,0 +1,267 @@
+package docdiff
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// extractFixture 从 testdata 中的样例文件提取文本
+func extractFixture(t *testing.T, name string) *Document {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("读取样例 %s: %v", name, err)
+	}
+	doc, err := Extract(bytes.NewReader(data), int64(len(data)), FormatOf(name))
+	if err != nil {
+		t.Fatalf("Extract(%s): %v", name, err)
+	}
+	return doc
+}
+
+func TestFormatOf(t *testing.T) {
+	for name, want := range map[string]string{
+		"报告.DOCX":   FormatDocx,
+		"a.dotm":    FormatDocx,
+		"b.xltx":    FormatXlsx,
+		"c.ppsx":    FormatPptx,
+		"notes.txt": FormatText,
+		"t.csv":     FormatCSV,
+		"old.doc":   "",
+		"noext":     "",
+	} {
+		if got := FormatOf(name); got != want {
+			t.Errorf("FormatOf(%q) = %q，期望 %q", name, got, want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []Unit
+	}{
+		{
+			// 跨 run 的文字合并为一段，制表符保留，空段落、页眉与修订中删除的文字不提取
+			fixture: "report-v1.docx",
+			want: []Unit{
+				{KindParagraph, "1", "季度报告"},
+				{KindParagraph, "2", "第一部分\t概述"},
+				{KindParagraph, "3", "结论：保持"},
+			},
+		},
+		{
+			// 共享字符串（忽略注音）、内联字符串、公式缓存值与布尔值；缺少 r 属性的行按顺序推算位置
+			fixture: "data-v1.xlsx",
+			want: []Unit{
+				{KindCell, "数据!A1", "名称"},
+				{KindCell, "数据!B1", "数量"},
+				{KindCell, "数据!A2", "苹果"},
+				{KindCell, "数据!B2", "3"},
+				{KindCell, "数据!C2", "6"},
+				{KindCell, "数据!A3", "TRUE"},
+				{KindCell, "备注!A1", "东京"},
+				{KindCell, "备注!A2", "备注"},
+				{KindCell, "备注!B2", "1.5"},
+			},
+		},
+		{
+			// 幻灯片顺序以 sldIdLst 为准而不是部件名，空白段落不提取，a:br 为换行
+			fixture: "deck-v2.pptx",
+			want: []Unit{
+				{KindSlide, "1", "封面"},
+				{KindSlide, "2", "要点一\n补充\n要点三"},
+				{KindSlide, "3", "谢谢"},
+			},
+		},
+		{
+			// 去掉 UTF-8 BOM 与行尾的 \r
+			fixture: "notes-v1.txt",
+			want: []Unit{
+				{KindLine, "1", "第一行"},
+				{KindLine, "2", "第二行"},
+				{KindLine, "3", "第三行"},
+			},
+		},
+		{
+			// 空单元格不提取，位置与电子表格一致
+			fixture: "table-v2.csv",
+			want: []Unit{
+				{KindCell, "A1", "名称"},
+				{KindCell, "B1", "数量"},
+				{KindCell, "A2", "苹果"},
+				{KindCell, "B2", "5"},
+				{KindCell, "B3", "备注"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.fixture, func(t *testing.T) {
+			doc := extractFixture(t, tc.fixture)
+			if doc.Format != FormatOf(tc.fixture) {
+				t.Errorf("格式为 %q", doc.Format)
+			}
+			if !reflect.DeepEqual(doc.Units, tc.want) {
+				t.Errorf("提取结果\n%q\n期望\n%q", doc.Units, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompareFixtures(t *testing.T) {
+	tests := []struct {
+		from, to string
+		changes  []Change
+		stats    Stats
+	}{
+		{
+			from: "report-v1.docx", to: "report-v2.docx",
+			changes: []Change{
+				{Op: OpModify, Kind: KindParagraph, OldRef: "1", NewRef: "1", Old: "季度报告", New: "2024 季度报告"},
+				{Op: OpInsert, Kind: KindParagraph, NewRef: "3", New: "新增段落"},
+			},
+			stats: Stats{Inserted: 1, Modified: 1, Unchanged: 2},
+		},
+		{
+			// 单元格按位置比较，结果按新版本顺序排列，删除项在最后
+			from: "data-v1.xlsx", to: "data-v2.xlsx",
+			changes: []Change{
+				{Op: OpInsert, Kind: KindCell, NewRef: "数据!D1", New: "单价"},
+				{Op: OpModify, Kind: KindCell, OldRef: "数据!B2", NewRef: "数据!B2", Old: "3", New: "5"},
+				{Op: OpModify, Kind: KindCell, OldRef: "数据!C2", NewRef: "数据!C2", Old: "6", New: "10"},
+				{Op: OpDelete, Kind: KindCell, OldRef: "数据!A3", Old: "TRUE"},
+			},
+			stats: Stats{Inserted: 1, Deleted: 1, Modified: 2, Unchanged: 6},
+		},
+		{
+			from: "deck-v1.pptx", to: "deck-v2.pptx",
+			changes: []Change{
+				{Op: OpModify, Kind: KindSlide, OldRef: "2", NewRef: "2", Old: "要点一\n要点二", New: "要点一\n补充\n要点三"},
+				{Op: OpInsert, Kind: KindSlide, NewRef: "3", New: "谢谢"},
+			},
+			stats: Stats{Inserted: 1, Modified: 1, Unchanged: 1},
+		},
+		{
+			from: "notes-v1.txt", to: "notes-v2.txt",
+			changes: []Change{
+				{Op: OpModify, Kind: KindLine, OldRef: "2", NewRef: "2", Old: "第二行", New: "第二行（修改）"},
+				{Op: OpInsert, Kind: KindLine, NewRef: "4", New: "第四行"},
+			},
+			stats: Stats{Inserted: 1, Modified: 1, Unchanged: 2},
+		},
+		{
+			from: "table-v1.csv", to: "table-v2.csv",
+			changes: []Change{
+				{Op: OpModify, Kind: KindCell, OldRef: "B2", NewRef: "B2", Old: "3", New: "5"},
+				{Op: OpInsert, Kind: KindCell, NewRef: "B3", New: "备注"},
+			},
+			stats: Stats{Inserted: 1, Modified: 1, Unchanged: 3},
+		},
+		{
+			// 相同内容没有差异，Changes 为空切片而不是 nil
+			from: "report-v1.docx", to: "report-v1.docx",
+			changes: []Change{},
+			stats:   Stats{Unchanged: 3},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.from+"→"+tc.to, func(t *testing.T) {
+			result, err := Compare(extractFixture(t, tc.from), extractFixture(t, tc.to))
+			if err != nil {
+				t.Fatalf("Compare: %v", err)
+			}
+			if !reflect.DeepEqual(result.Changes, tc.changes) {
+				t.Errorf("差异\n%+v\n期望\n%+v", result.Changes, tc.changes)
+			}
+			if result.Stats != tc.stats {
+				t.Errorf("统计 %+v，期望 %+v", result.Stats, tc.stats)
+			}
+		})
+	}
+}
+
+func TestCompareSequenceDeletes(t *testing.T) {
+	lines := func(texts ...string) *Document {
+		doc := &Document{Format: FormatText}
+		for i, text := range texts {
+			doc.Units = append(doc.Units, Unit{Kind: KindLine, Ref: string(rune('1' + i)), Text: text})
+		}
+		return doc
+	}
+	result, err := Compare(lines("a", "b", "c", "d"), lines("a", "d"))
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	want := []Change{
+		{Op: OpDelete, Kind: KindLine, OldRef: "2", Old: "b"},
+		{Op: OpDelete, Kind: KindLine, OldRef: "3", Old: "c"},
+	}
+	if !reflect.DeepEqual(result.Changes, want) || result.Stats != (Stats{Deleted: 2, Unchanged: 2}) {
+		t.Errorf("Compare 返回 %+v", result)
+	}
+}
+
+// zipParts 生成只含给定部件的 zip 包
+func zipParts(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractErrors(t *testing.T) {
+	sheet := `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="A1" t="s"><v>7</v></c></row></sheetData></worksheet>`
+	workbook := `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="S" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	workbookRels := `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+	tests := []struct {
+		name    string
+		data    []byte
+		format  string
+		wantErr error
+	}{
+		{"not a zip", []byte("plain text"), FormatDocx, ErrInvalidDocument},
+		{"missing main part", zipParts(t, map[string]string{"[Content_Types].xml": "<Types/>"}), FormatDocx, ErrInvalidDocument},
+		{"malformed xml", zipParts(t, map[string]string{"word/document.xml": "<w:document><w:body>"}), FormatDocx, ErrInvalidDocument},
+		{"bad shared string", zipParts(t, map[string]string{
+			"xl/workbook.xml":            workbook,
+			"xl/_rels/workbook.xml.rels": workbookRels,
+			"xl/worksheets/sheet1.xml":   sheet,
+		}), FormatXlsx, ErrInvalidDocument},
+		{"unsupported", []byte("x"), "doc", ErrUnsupported},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Extract(bytes.NewReader(tc.data), int64(len(tc.data)), tc.format)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Extract 返回错误 %v，期望 %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareFormatMismatch(t *testing.T) {
+	_, err := Compare(&Document{Format: FormatDocx}, &Document{Format: FormatText})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Compare 不同格式返回 %v，期望 ErrUnsupported", err)
+	}
+}
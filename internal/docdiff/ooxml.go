@@ -0,0 +1,482 @@
+package docdiff
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// 单个 XML 部件读取的最大字节数，防止压缩炸弹
+const maxPartSize = 64 << 20
+
+const (
+	nsWordprocessing = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	nsSpreadsheet    = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	nsDrawing        = "http://schemas.openxmlformats.org/drawingml/2006/main"
+	nsRelationships  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	relOfficeDocument = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+)
+
+var errPartTooLarge = fmt.Errorf("%w: 部件超过 %d 字节", ErrInvalidDocument, maxPartSize)
+
+// ooxmlPackage 已打开的 OOXML 包，按去掉前导 / 的部件名索引
+type ooxmlPackage struct {
+	parts map[string]*zip.File
+}
+
+func openPackage(r io.ReaderAt, size int64) (*ooxmlPackage, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	pkg := &ooxmlPackage{parts: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		pkg.parts[strings.TrimPrefix(f.Name, "/")] = f
+	}
+	return pkg, nil
+}
+
+// walk 逐个读取部件中的 XML 标记；部件不存在时返回 ErrInvalidDocument
+func (p *ooxmlPackage) walk(name string, fn func(dec *xml.Decoder, tok xml.Token) error) error {
+	f, ok := p.parts[name]
+	if !ok {
+		return fmt.Errorf("%w: 缺少部件 %s", ErrInvalidDocument, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidDocument, name, err)
+	}
+	defer rc.Close()
+
+	lr := &io.LimitedReader{R: rc, N: maxPartSize + 1}
+	dec := xml.NewDecoder(lr)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if lr.N <= 0 {
+				return errPartTooLarge
+			}
+			return fmt.Errorf("%w: %s: %v", ErrInvalidDocument, name, err)
+		}
+		if err := fn(dec, tok); err != nil {
+			return err
+		}
+	}
+}
+
+// relationships 读取部件的关系，返回关系 ID 到目标部件名的映射
+func (p *ooxmlPackage) relationships(source string) (map[string]string, map[string]string, error) {
+	dir, file := path.Split(source)
+	relsName := dir + "_rels/" + file + ".rels"
+	byID := make(map[string]string)
+	byType := make(map[string]string)
+	if _, ok := p.parts[relsName]; !ok {
+		return byID, byType, nil
+	}
+	err := p.walk(relsName, func(_ *xml.Decoder, tok xml.Token) error {
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "Relationship" {
+			return nil
+		}
+		var id, typ, target, mode string
+		for _, attr := range se.Attr {
+			switch attr.Name.Local {
+			case "Id":
+				id = attr.Value
+			case "Type":
+				typ = attr.Value
+			case "Target":
+				target = attr.Value
+			case "TargetMode":
+				mode = attr.Value
+			}
+		}
+		if mode == "External" {
+			return nil
+		}
+		// 目标相对于源部件所在目录，以 / 开头时为包内绝对路径
+		if strings.HasPrefix(target, "/") {
+			target = path.Clean(target)
+		} else {
+			target = path.Clean("/" + dir + target)
+		}
+		target = strings.TrimPrefix(target, "/")
+		byID[id] = target
+		if _, ok := byType[typ]; !ok {
+			byType[typ] = target
+		}
+		return nil
+	})
+	return byID, byType, err
+}
+
+// mainPart 返回包的主文档部件，缺少关系时使用 fallback
+func (p *ooxmlPackage) mainPart(fallback string) (string, error) {
+	_, byType, err := p.relationships("")
+	if err != nil {
+		return "", err
+	}
+	if target, ok := byType[relOfficeDocument]; ok {
+		return target, nil
+	}
+	return fallback, nil
+}
+
+// paragraphCollector 收集 w:p / a:p 段落文本，支持文本框等嵌套段落
+type paragraphCollector struct {
+	ns     string
+	stack  []*strings.Builder
+	inText bool
+}
+
+// handle 处理一个标记，段落结束时返回其文本
+func (pc *paragraphCollector) handle(tok xml.Token) (string, bool) {
+	switch t := tok.(type) {
+	case xml.StartElement:
+		if t.Name.Space != pc.ns {
+			return "", false
+		}
+		switch t.Name.Local {
+		case "p":
+			pc.stack = append(pc.stack, &strings.Builder{})
+		case "t":
+			pc.inText = true
+		case "tab":
+			pc.write("\t")
+		case "br", "cr":
+			pc.write("\n")
+		}
+	case xml.EndElement:
+		if t.Name.Space != pc.ns {
+			return "", false
+		}
+		switch t.Name.Local {
+		case "t":
+			pc.inText = false
+		case "p":
+			if len(pc.stack) == 0 {
+				return "", false
+			}
+			text := pc.stack[len(pc.stack)-1].String()
+			pc.stack = pc.stack[:len(pc.stack)-1]
+			return text, true
+		}
+	case xml.CharData:
+		if pc.inText {
+			pc.write(string(t))
+		}
+	}
+	return "", false
+}
+
+func (pc *paragraphCollector) write(s string) {
+	if len(pc.stack) > 0 {
+		pc.stack[len(pc.stack)-1].WriteString(s)
+	}
+}
+
+// extractDocx 按段落提取正文文本（不含页眉页脚、批注与修订中删除的文字），忽略空段落
+func extractDocx(r io.ReaderAt, size int64) ([]Unit, error) {
+	pkg, err := openPackage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	main, err := pkg.mainPart("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var units []Unit
+	pc := &paragraphCollector{ns: nsWordprocessing}
+	err = pkg.walk(main, func(_ *xml.Decoder, tok xml.Token) error {
+		if text, ok := pc.handle(tok); ok && strings.TrimSpace(text) != "" {
+			units = append(units, Unit{Kind: KindParagraph, Ref: strconv.Itoa(len(units) + 1), Text: text})
+		}
+		return nil
+	})
+	return units, err
+}
+
+// extractPptx 按幻灯片顺序提取文本，每张幻灯片的段落以换行连接
+func extractPptx(r io.ReaderAt, size int64) ([]Unit, error) {
+	pkg, err := openPackage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	main, err := pkg.mainPart("ppt/presentation.xml")
+	if err != nil {
+		return nil, err
+	}
+	rels, _, err := pkg.relationships(main)
+	if err != nil {
+		return nil, err
+	}
+
+	// 幻灯片顺序以 p:sldIdLst 为准
+	var slides []string
+	err = pkg.walk(main, func(_ *xml.Decoder, tok xml.Token) error {
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "sldId" {
+			return nil
+		}
+		for _, attr := range se.Attr {
+			if attr.Name.Space == nsRelationships && attr.Name.Local == "id" {
+				if target, ok := rels[attr.Value]; ok {
+					slides = append(slides, target)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	units := make([]Unit, 0, len(slides))
+	for i, slide := range slides {
+		var paragraphs []string
+		pc := &paragraphCollector{ns: nsDrawing}
+		if err := pkg.walk(slide, func(_ *xml.Decoder, tok xml.Token) error {
+			if text, ok := pc.handle(tok); ok && strings.TrimSpace(text) != "" {
+				paragraphs = append(paragraphs, text)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		units = append(units, Unit{Kind: KindSlide, Ref: strconv.Itoa(i + 1), Text: strings.Join(paragraphs, "\n")})
+	}
+	return units, nil
+}
+
+// extractXlsx 按工作表顺序提取非空单元格的显示值（公式取缓存结果），位置形如 Sheet1!A1
+func extractXlsx(r io.ReaderAt, size int64) ([]Unit, error) {
+	pkg, err := openPackage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	main, err := pkg.mainPart("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	rels, _, err := pkg.relationships(main)
+	if err != nil {
+		return nil, err
+	}
+
+	type sheet struct{ name, part string }
+	var sheets []sheet
+	err = pkg.walk(main, func(_ *xml.Decoder, tok xml.Token) error {
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Space != nsSpreadsheet || se.Name.Local != "sheet" {
+			return nil
+		}
+		var s sheet
+		for _, attr := range se.Attr {
+			switch {
+			case attr.Name.Local == "name" && attr.Name.Space == "":
+				s.name = attr.Value
+			case attr.Name.Local == "id" && attr.Name.Space == nsRelationships:
+				s.part = rels[attr.Value]
+			}
+		}
+		// 图表工作表等没有单元格的部件在解析时自然为空
+		if s.part != "" {
+			sheets = append(sheets, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := readSharedStrings(pkg, path.Dir(main)+"/sharedStrings.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var units []Unit
+	for _, s := range sheets {
+		cells, err := readSheet(pkg, s.part, shared)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cells {
+			units = append(units, Unit{Kind: KindCell, Ref: s.name + "!" + c.ref, Text: c.value})
+		}
+	}
+	return units, nil
+}
+
+// readSharedStrings 读取共享字符串表，不存在时返回空表；忽略注音（rPh）
+func readSharedStrings(pkg *ooxmlPackage, name string) ([]string, error) {
+	if _, ok := pkg.parts[name]; !ok {
+		return nil, nil
+	}
+	var (
+		items   []string
+		current strings.Builder
+		inText  bool
+		inRPh   bool
+	)
+	err := pkg.walk(name, func(_ *xml.Decoder, tok xml.Token) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "rPh":
+				inRPh = true
+			case "t":
+				inText = !inRPh
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				items = append(items, current.String())
+			case "rPh":
+				inRPh = false
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+		return nil
+	})
+	return items, err
+}
+
+type sheetCell struct {
+	ref   string
+	value string
+}
+
+// readSheet 读取工作表中的非空单元格；缺少 r 属性的行与单元格按出现顺序推算位置
+func readSheet(pkg *ooxmlPackage, name string, shared []string) ([]sheetCell, error) {
+	var (
+		cells          []sheetCell
+		row, col       int
+		cellRef, typ   string
+		value, inline  strings.Builder
+		inValue, inStr bool
+	)
+	err := pkg.walk(name, func(_ *xml.Decoder, tok xml.Token) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != nsSpreadsheet {
+				return nil
+			}
+			switch t.Name.Local {
+			case "row":
+				row++
+				col = 0
+				if r, err := strconv.Atoi(attrValue(t, "r")); err == nil && r > 0 {
+					row = r
+				}
+			case "c":
+				col++
+				cellRef, typ = attrValue(t, "r"), attrValue(t, "t")
+				if c, r, ok := parseCellRef(cellRef); ok {
+					col, row = c, r
+				} else {
+					cellRef = cellName(col, row)
+				}
+				value.Reset()
+				inline.Reset()
+			case "v":
+				inValue = true
+			case "is":
+				inStr = true
+			}
+		case xml.EndElement:
+			if t.Name.Space != nsSpreadsheet {
+				return nil
+			}
+			switch t.Name.Local {
+			case "v":
+				inValue = false
+			case "is":
+				inStr = false
+			case "c":
+				text := value.String()
+				switch typ {
+				case "s":
+					i, err := strconv.Atoi(strings.TrimSpace(text))
+					if err != nil || i < 0 || i >= len(shared) {
+						return fmt.Errorf("%w: %s 单元格 %s 引用了无效的共享字符串", ErrInvalidDocument, name, cellRef)
+					}
+					text = shared[i]
+				case "inlineStr":
+					text = inline.String()
+				case "b":
+					if text == "1" {
+						text = "TRUE"
+					} else {
+						text = "FALSE"
+					}
+				}
+				if text != "" {
+					cells = append(cells, sheetCell{ref: cellRef, value: text})
+				}
+			}
+		case xml.CharData:
+			switch {
+			case inValue:
+				value.Write(t)
+			case inStr:
+				inline.Write(t)
+			}
+		}
+		return nil
+	})
+	return cells, err
+}
+
+func attrValue(se xml.StartElement, local string) string {
+	for _, attr := range se.Attr {
+		if attr.Name.Local == local && attr.Name.Space == "" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// parseCellRef 解析 A1 形式的单元格位置，返回从 1 开始的列号与行号
+func parseCellRef(ref string) (col, row int, ok bool) {
+	i := 0
+	for i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z' {
+		col = col*26 + int(ref[i]-'A'+1)
+		i++
+	}
+	if i == 0 || i == len(ref) {
+		return 0, 0, false
+	}
+	row, err := strconv.Atoi(ref[i:])
+	if err != nil || row <= 0 {
+		return 0, 0, false
+	}
+	return col, row, true
+}
+
+// cellName 由从 1 开始的列号与行号生成 A1 形式的位置
+func cellName(col, row int) string {
+	var letters []byte
+	for col > 0 {
+		col--
+		letters = append([]byte{byte('A' + col%26)}, letters...)
+		col /= 26
+	}
+	return string(letters) + strconv.Itoa(row)
+}
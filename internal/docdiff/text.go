@@ -0,0 +1,81 @@
+package docdiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 纯文本与 CSV 读取的最大字节数
+const maxTextSize = 64 << 20
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// readText 读取 UTF-8 文本并去掉 BOM
+func readText(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxTextSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTextSize {
+		return nil, fmt.Errorf("%w: 文本超过 %d 字节", ErrInvalidDocument, maxTextSize)
+	}
+	return bytes.TrimPrefix(data, utf8BOM), nil
+}
+
+// extractText 按行提取文本，行号从 1 开始
+func extractText(r io.Reader) ([]Unit, error) {
+	data, err := readText(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []Unit
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxTextSize)
+	for scanner.Scan() {
+		units = append(units, Unit{
+			Kind: KindLine,
+			Ref:  strconv.Itoa(len(units) + 1),
+			Text: strings.TrimSuffix(scanner.Text(), "\r"),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	return units, nil
+}
+
+// extractCSV 提取非空单元格，位置与电子表格一致（A1 形式）
+func extractCSV(r io.Reader) ([]Unit, error) {
+	data, err := readText(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var units []Unit
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		for i, field := range record {
+			if field != "" {
+				units = append(units, Unit{Kind: KindCell, Ref: cellName(i+1, row), Text: field})
+			}
+		}
+	}
+	return units, nil
+}
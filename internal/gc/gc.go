@@ -0,0 +1,200 @@
+// Package gc 核对存储后端与数据库，清理已不再被引用的版本内容、附件对象与 Blob
+package gc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"weboffice/internal/models"
+	"weboffice/internal/storage"
+)
+
+// Report 一次垃圾回收的结果，DryRun 时为将被删除的内容
+type Report struct {
+	DryRun   bool
+	Blobs    int   // 引用计数归零的 Blob
+	Versions int   // 没有版本记录的按版本号存储的内容
+	Objects  int   // 没有 Blob 或附件引用的对象（含写入中断残留的临时文件）
+	Bytes    int64 // 涉及的总字节数
+	Errors   int   // 删除失败的条目数，下次执行时重试
+}
+
+func (r *Report) String() string {
+	action := "已删除"
+	if r.DryRun {
+		action = "将删除"
+	}
+	return fmt.Sprintf("%s Blob %d 个、版本内容 %d 个、对象 %d 个，共 %d 字节，失败 %d 个",
+		action, r.Blobs, r.Versions, r.Objects, r.Bytes, r.Errors)
+}
+
+// Collector 垃圾回收器：只删除未被引用且超过宽限期的内容，
+// 宽限期用于避开尚未提交的事务已写入存储、但数据库记录还不可见的内容
+type Collector struct {
+	db    *gorm.DB
+	store storage.Storage
+	grace time.Duration
+	mu    sync.Mutex // 定时任务与手动执行不并发
+}
+
+func NewCollector(db *gorm.DB, store storage.Storage, grace time.Duration) *Collector {
+	return &Collector{db: db, store: store, grace: grace}
+}
+
+// Run 执行一次垃圾回收，dryRun 为真时只统计并记录将被删除的内容；
+// 单个条目删除失败只计入 Report.Errors，不中断本次执行
+func (c *Collector) Run(dryRun bool) (*Report, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := time.Now().Add(-c.grace)
+	report := &Report{DryRun: dryRun}
+	if err := c.collectBlobs(cutoff, report); err != nil {
+		return report, err
+	}
+	if err := c.collectVersions(cutoff, report); err != nil {
+		return report, err
+	}
+	if err := c.collectObjects(cutoff, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// Start 启动后台任务，按 interval 定期执行垃圾回收
+func (c *Collector) Start(interval time.Duration, dryRun bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := c.Run(dryRun)
+			if err != nil {
+				log.Printf("垃圾回收失败: %v", err)
+				continue
+			}
+			log.Printf("垃圾回收完成: %s", report)
+		}
+	}()
+}
+
+// collectBlobs 删除引用计数归零且超过宽限期的 Blob：先在事务内删除记录再删除内容，
+// 并发登记同一内容的事务会等待本事务结束后重新插入记录
+func (c *Collector) collectBlobs(cutoff time.Time, report *Report) error {
+	var blobs []models.Blob
+	if err := c.db.Where("ref_count = 0 AND updated_at < ?", cutoff.Unix()).Find(&blobs).Error; err != nil {
+		return fmt.Errorf("查询未引用的 Blob 失败: %w", err)
+	}
+
+	for _, blob := range blobs {
+		if report.DryRun {
+			log.Printf("[gc] 将删除 Blob %s（%d 字节）", blob.Sha256, blob.Size)
+			report.Blobs++
+			report.Bytes += blob.Size
+			continue
+		}
+		err := c.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("sha256 = ? AND ref_count = 0 AND updated_at < ?", blob.Sha256, cutoff.Unix()).
+				Delete(&models.Blob{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errSkipped
+			}
+			return c.store.DeleteObject(blob.Sha256)
+		})
+		switch {
+		case errors.Is(err, errSkipped):
+		case err != nil:
+			log.Printf("[gc] 删除 Blob %s 失败: %v", blob.Sha256, err)
+			report.Errors++
+		default:
+			log.Printf("[gc] 已删除 Blob %s（%d 字节）", blob.Sha256, blob.Size)
+			report.Blobs++
+			report.Bytes += blob.Size
+		}
+	}
+	return nil
+}
+
+// errSkipped Blob 在查询后重新被引用，跳过
+var errSkipped = errors.New("gc: 已跳过")
+
+// collectVersions 删除按版本号存储、但没有对应版本记录（或版本内容已改由 Blob 保存）的内容
+func (c *Collector) collectVersions(cutoff time.Time, report *Report) error {
+	infos, err := c.store.ListAllVersions()
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		if info.ModTime.After(cutoff) {
+			continue
+		}
+		var referenced int64
+		if err := c.db.Model(&models.FileVersion{}).
+			Where("id = ? AND version = ? AND (sha256 = '' OR sha256 IS NULL)", info.FileID, info.Version).
+			Count(&referenced).Error; err != nil {
+			return fmt.Errorf("查询版本记录失败: %w", err)
+		}
+		if referenced > 0 {
+			continue
+		}
+
+		if report.DryRun {
+			log.Printf("[gc] 将删除版本内容 %s/v%d（%d 字节）", info.FileID, info.Version, info.Size)
+		} else if err := c.store.DeleteFile(info.FileID, info.Version); err != nil {
+			log.Printf("[gc] 删除版本内容 %s/v%d 失败: %v", info.FileID, info.Version, err)
+			report.Errors++
+			continue
+		} else {
+			log.Printf("[gc] 已删除版本内容 %s/v%d（%d 字节）", info.FileID, info.Version, info.Size)
+		}
+		report.Versions++
+		report.Bytes += info.Size
+	}
+	return nil
+}
+
+// collectObjects 删除既不是 Blob 也未被附件直接引用的对象
+func (c *Collector) collectObjects(cutoff time.Time, report *Report) error {
+	objects, err := c.store.ListObjects()
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if obj.ModTime.After(cutoff) {
+			continue
+		}
+		var blobs, attachments int64
+		if err := c.db.Model(&models.Blob{}).Where("sha256 = ?", obj.Key).Count(&blobs).Error; err != nil {
+			return fmt.Errorf("查询 Blob 失败: %w", err)
+		}
+		if err := c.db.Model(&models.Attachment{}).Where("storage_key = ?", obj.Key).
+			Count(&attachments).Error; err != nil {
+			return fmt.Errorf("查询附件失败: %w", err)
+		}
+		if blobs > 0 || attachments > 0 {
+			continue
+		}
+
+		if report.DryRun {
+			log.Printf("[gc] 将删除对象 %s（%d 字节）", obj.Key, obj.Size)
+		} else if err := c.store.DeleteObject(obj.Key); err != nil {
+			log.Printf("[gc] 删除对象 %s 失败: %v", obj.Key, err)
+			report.Errors++
+			continue
+		} else {
+			log.Printf("[gc] 已删除对象 %s（%d 字节）", obj.Key, obj.Size)
+		}
+		report.Objects++
+		report.Bytes += obj.Size
+	}
+	return nil
+}
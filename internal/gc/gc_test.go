@@ -0,0 +1,218 @@
+package gc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"weboffice/internal/config"
+	"weboffice/internal/database"
+	"weboffice/internal/migrations"
+	"weboffice/internal/models"
+	"weboffice/internal/storage"
+)
+
+// newTestCollector 返回使用临时 SQLite 数据库与内存存储的回收器
+func newTestCollector(t *testing.T) (*Collector, *gorm.DB, *storage.MemoryStorage) {
+	t.Helper()
+	db, err := database.Connect(&config.DBConfig{
+		Driver: database.DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "weboffice.db"),
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	store := storage.NewMemoryStorage()
+	if _, err := migrations.NewRunner(db, store).Up(0); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	return NewCollector(db, store, time.Hour), db, store
+}
+
+// splitAtCutoff 先写入 before 中的内容，再返回截止时间并写入 after 中的内容，
+// 模拟超过宽限期与仍在宽限期内的存储内容
+func splitAtCutoff(t *testing.T, before, after func()) time.Time {
+	t.Helper()
+	before()
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	after()
+	return cutoff
+}
+
+func putObject(t *testing.T, store storage.Storage, key, content string) {
+	t.Helper()
+	if _, err := store.PutObject(key, strings.NewReader(content)); err != nil {
+		t.Fatalf("PutObject(%s): %v", key, err)
+	}
+}
+
+func saveVersion(t *testing.T, store storage.Storage, fileID string, version int) {
+	t.Helper()
+	if err := store.SaveFile(fileID, version, "a.docx", strings.NewReader("content")); err != nil {
+		t.Fatalf("SaveFile(%s, %d): %v", fileID, version, err)
+	}
+}
+
+func objectExists(store storage.Storage, key string) bool {
+	rc, err := store.GetObject(key)
+	if err != nil {
+		return false
+	}
+	rc.Close()
+	return true
+}
+
+func TestCollectBlobs(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		c, db, store := newTestCollector(t)
+		cutoff := time.Now()
+		old, recent := cutoff.Add(-time.Minute).Unix(), cutoff.Add(time.Minute).Unix()
+		blobs := []models.Blob{
+			{Sha256: "orphan", Size: 10, RefCount: 0, CreatedAt: old, UpdatedAt: old},
+			{Sha256: "released-recently", Size: 20, RefCount: 0, CreatedAt: old, UpdatedAt: recent},
+			{Sha256: "referenced", Size: 30, RefCount: 2, CreatedAt: old, UpdatedAt: old},
+		}
+		for _, b := range blobs {
+			if err := db.Create(&b).Error; err != nil {
+				t.Fatal(err)
+			}
+			putObject(t, store, b.Sha256, "x")
+		}
+
+		report := &Report{DryRun: dryRun}
+		if err := c.collectBlobs(cutoff, report); err != nil {
+			t.Fatalf("collectBlobs: %v", err)
+		}
+		if report.Blobs != 1 || report.Bytes != 10 || report.Errors != 0 {
+			t.Errorf("dryRun=%v 统计 %+v", dryRun, report)
+		}
+
+		var remaining []string
+		db.Model(&models.Blob{}).Order("sha256").Pluck("sha256", &remaining)
+		want := "referenced,released-recently"
+		if dryRun {
+			want = "orphan," + want
+		}
+		if strings.Join(remaining, ",") != want {
+			t.Errorf("dryRun=%v 剩余 Blob %q，期望 %s", dryRun, remaining, want)
+		}
+		if objectExists(store, "orphan") == !dryRun {
+			t.Errorf("dryRun=%v 未引用 Blob 的内容存在状态不正确", dryRun)
+		}
+		for _, key := range []string{"released-recently", "referenced"} {
+			if !objectExists(store, key) {
+				t.Errorf("dryRun=%v 内容 %s 不应被删除", dryRun, key)
+			}
+		}
+	}
+}
+
+func TestCollectVersions(t *testing.T) {
+	c, db, store := newTestCollector(t)
+	cutoff := splitAtCutoff(t, func() {
+		saveVersion(t, store, "f1", 1) // 旧版本按版本号存储，仍被引用
+		saveVersion(t, store, "f1", 2) // 版本内容已改由 Blob 保存
+		saveVersion(t, store, "f2", 1) // 版本记录已被删除
+	}, func() {
+		saveVersion(t, store, "f3", 1) // 提交事务尚未可见
+	})
+	for _, v := range []models.FileVersion{
+		{ID: "f1", Version: 1, ModifierID: "u"},
+		{ID: "f1", Version: 2, ModifierID: "u", Sha256: "abc"},
+	} {
+		if err := db.Create(&v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := &Report{}
+	if err := c.collectVersions(cutoff, report); err != nil {
+		t.Fatalf("collectVersions: %v", err)
+	}
+	if report.Versions != 2 || report.Bytes != int64(2*len("content")) || report.Errors != 0 {
+		t.Errorf("统计 %+v", report)
+	}
+
+	infos, err := store.ListAllVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, info := range infos {
+		remaining = append(remaining, info.Key)
+	}
+	if strings.Join(remaining, ",") != "f1/v1,f3/v1" {
+		t.Errorf("剩余版本内容 %q", remaining)
+	}
+}
+
+func TestCollectObjects(t *testing.T) {
+	c, db, store := newTestCollector(t)
+	cutoff := splitAtCutoff(t, func() {
+		putObject(t, store, "blob", "12345")
+		putObject(t, store, "attachment", "123")
+		putObject(t, store, "orphan", "1")
+		putObject(t, store, ".tmp-upload", "12")
+	}, func() {
+		putObject(t, store, "pending", "1234")
+	})
+	now := time.Now().Unix()
+	if err := db.Create(&models.Blob{Sha256: "blob", Size: 5, RefCount: 1, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Attachment{Key: "a1", Size: 3, StorageKey: "attachment", CreatedAt: now}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report := &Report{DryRun: true}
+	if err := c.collectObjects(cutoff, report); err != nil {
+		t.Fatalf("collectObjects: %v", err)
+	}
+	if report.Objects != 2 || report.Bytes != 3 {
+		t.Errorf("DryRun 统计 %+v", report)
+	}
+	if !objectExists(store, "orphan") {
+		t.Error("DryRun 不应删除内容")
+	}
+
+	report = &Report{}
+	if err := c.collectObjects(cutoff, report); err != nil {
+		t.Fatalf("collectObjects: %v", err)
+	}
+	if report.Objects != 2 || report.Errors != 0 {
+		t.Errorf("统计 %+v", report)
+	}
+	for key, want := range map[string]bool{"blob": true, "attachment": true, "pending": true, "orphan": false, ".tmp-upload": false} {
+		if objectExists(store, key) != want {
+			t.Errorf("对象 %s 存在状态应为 %v", key, want)
+		}
+	}
+}
+
+func TestRunKeepsContentWithinGrace(t *testing.T) {
+	c, db, store := newTestCollector(t)
+	saveVersion(t, store, "f1", 1)
+	putObject(t, store, "orphan", "1")
+	now := time.Now().Unix()
+	if err := db.Create(&models.Blob{Sha256: "released", Size: 1, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.Run(false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Blobs != 0 || report.Versions != 0 || report.Objects != 0 {
+		t.Errorf("宽限期内的内容不应被删除: %s", report)
+	}
+}
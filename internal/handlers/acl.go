@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weboffice/internal/database"
+	"weboffice/internal/middleware"
+	"weboffice/internal/models"
+	"weboffice/internal/utils"
+)
+
+// errPermissionDenied 事务内权限校验失败
+var errPermissionDenied = errors.New("permission denied")
+
+// 权限名称与权限位的对应关系，用于管理接口的输入输出
+var permissionNames = map[string]int{
+	"read":     models.PermRead,
+	"update":   models.PermUpdate,
+	"download": models.PermDownload,
+	"history":  models.PermHistory,
+	"copy":     models.PermCopy,
+	"print":    models.PermPrint,
+	"manage":   models.PermManage,
+	"comment":  models.PermComment,
+}
+
+// parsePermissions 将权限名称列表转换为权限位
+func parsePermissions(names []string) (int, error) {
+	perms := 0
+	for _, name := range names {
+		bit, ok := permissionNames[name]
+		if !ok {
+			return 0, fmt.Errorf("未知的权限: %s", name)
+		}
+		perms |= bit
+	}
+	return perms, nil
+}
+
+// formatPermissions 将权限位转换为有序的权限名称列表
+func formatPermissions(perms int) []string {
+	names := make([]string, 0, len(permissionNames))
+	for name, bit := range permissionNames {
+		if perms&bit != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// filePermissions 计算用户对文件的有效权限：所有者拥有全部权限，
+// 分享访客仅拥有所属链接范围内的权限，其余用户为直接授予及所在用户组授予的权限之并集
+func filePermissions(tx *gorm.DB, file *models.File, userID string) (int, error) {
+	if userID == "" {
+		return 0, nil
+	}
+	if linkID, ok := middleware.ShareLinkID(userID); ok {
+		link, err := middleware.LoadActiveShareLink(tx, linkID)
+		if err != nil {
+			if errors.Is(err, middleware.ErrInvalidToken) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		if link.FileID != file.ID {
+			return 0, nil
+		}
+		return link.Permissions(), nil
+	}
+	if file.Owner() == userID {
+		return models.PermAll, nil
+	}
+
+	var entries []models.FileACL
+	if err := tx.Where("file_id = ? AND ((subject_type = ? AND subject_id = ?) OR (subject_type = ? AND subject_id IN (?)))",
+		file.ID, models.SubjectUser, userID, models.SubjectGroup,
+		tx.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Find(&entries).Error; err != nil {
+		return 0, fmt.Errorf("查询文件权限失败: %w", err)
+	}
+
+	perms := 0
+	for _, entry := range entries {
+		perms |= entry.Permissions
+	}
+	return perms, nil
+}
+
+// requireFilePermission 加载文件并校验当前用户拥有 perm 中的全部权限，
+// 校验失败时写入错误响应并返回 false
+func requireFilePermission(c *gin.Context, fileID string, perm int) (*models.File, bool) {
+	var file models.File
+	if err := database.DB.Where("id = ?", fileID).First(&file).Error; err != nil {
+		handleDatabaseError(c, err)
+		return nil, false
+	}
+
+	perms, err := filePermissions(database.DB, &file, middleware.CurrentUserID(c))
+	if err != nil {
+		handleDatabaseError(c, err)
+		return nil, false
+	}
+	if perms&perm != perm {
+		utils.ErrorResponse(c, http.StatusForbidden, "Permission denied")
+		return nil, false
+	}
+	return &file, true
+}
+
+// requireUploadPermission 校验当前用户可向文件上传新版本。
+// 已存在的文件需要更新权限；分享访客只能更新所属链接的文件，不能创建文件；
+// 文件尚不存在时，只有 users 表中登记的用户可以创建，创建者成为所有者
+func requireUploadPermission(c *gin.Context, fileID string) bool {
+	userID := middleware.CurrentUserID(c)
+	if _, ok := middleware.ShareLinkID(userID); ok {
+		_, ok := requireFilePermission(c, fileID, models.PermUpdate)
+		return ok
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.File{}).Where("id = ?", fileID).Count(&count).Error; err != nil {
+		handleDatabaseError(c, err)
+		return false
+	}
+	if count > 0 {
+		_, ok := requireFilePermission(c, fileID, models.PermUpdate)
+		return ok
+	}
+
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		handleDatabaseError(c, err)
+		return false
+	}
+	if userID == "" || count == 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "Permission denied")
+		return false
+	}
+	return true
+}
+
+// ListFileACL 列出文件所有者及权限条目（管理接口）
+func ListFileACL(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	file, ok := requireFilePermission(c, fileID, models.PermManage)
+	if !ok {
+		return
+	}
+
+	var entries []models.FileACL
+	if err := database.DB.Where("file_id = ?", fileID).
+		Order("subject_type, subject_id").
+		Find(&entries).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+
+	items := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, gin.H{
+			"subject_type": entry.SubjectType,
+			"subject_id":   entry.SubjectID,
+			"permissions":  formatPermissions(entry.Permissions),
+			"granted_by":   entry.GrantedBy,
+			"create_time":  entry.CreateTime,
+		})
+	}
+	utils.SuccessResponse(c, gin.H{
+		"owner_id": file.Owner(),
+		"entries":  items,
+	})
+}
+
+// GrantFileACL 为用户或用户组授予文件权限，已存在的条目将被覆盖（管理接口）
+func GrantFileACL(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	var req struct {
+		SubjectType string   `json:"subject_type"`
+		SubjectID   string   `json:"subject_id"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.SubjectType != models.SubjectUser && req.SubjectType != models.SubjectGroup {
+		utils.ErrorResponse(c, http.StatusBadRequest, "subject_type must be user or group")
+		return
+	}
+	subjectID := utils.SanitizeID(req.SubjectID)
+	if subjectID == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subject ID")
+		return
+	}
+	perms, err := parsePermissions(req.Permissions)
+	if err != nil || perms == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid permissions")
+		return
+	}
+
+	entry := models.FileACL{
+		FileID:      fileID,
+		SubjectType: req.SubjectType,
+		SubjectID:   subjectID,
+		Permissions: perms,
+		GrantedBy:   middleware.CurrentUserID(c),
+		CreateTime:  time.Now().Unix(),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}, {Name: "subject_type"}, {Name: "subject_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permissions", "granted_by", "create_time"}),
+	}).Create(&entry).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"subject_type": entry.SubjectType,
+		"subject_id":   entry.SubjectID,
+		"permissions":  formatPermissions(entry.Permissions),
+	})
+}
+
+// RevokeFileACL 撤销用户或用户组的文件权限（管理接口）
+func RevokeFileACL(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	result := database.DB.Where("file_id = ? AND subject_type = ? AND subject_id = ?",
+		fileID, c.Param("subject_type"), utils.SanitizeID(c.Param("subject_id"))).
+		Delete(&models.FileACL{})
+	if result.Error != nil {
+		handleDatabaseError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		handleDatabaseError(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
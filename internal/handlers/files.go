@@ -6,33 +6,44 @@ import (
 	"io" // 新增导入
 	"log"
 	"net/http"
-	"path/filepath" // 新增导入
 	"strconv"
 	"time"
 
 	"net/url" // 新增导入：用于文件名编码
-	"os"      // 新增导入：用于文件存在性检查
 
 	"gorm.io/gorm/clause" // 新增导入
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
-	"weboffice/internal/config" // 新增导入
+	"weboffice/internal/config"
 	"weboffice/internal/database"
+	"weboffice/internal/middleware"
 	"weboffice/internal/models"
 	"weboffice/internal/storage" // 新增导入
 	"weboffice/internal/utils"
 )
 
 // 添加全局存储实例
-var fileStorage *storage.FileStorage
+var fileStorage storage.Storage
 
-// InitFileStorage 正确类型声明
-func InitFileStorage(s *storage.FileStorage) {
+// 启动时加载的应用配置
+var appConfig *config.AppConfig
+
+// 由 AllowedFileTypes 配置构建的上传文件类型策略
+var fileTypeRules *utils.FileTypeRules
+
+// InitFileStorage 注入存储后端实现
+func InitFileStorage(s storage.Storage) {
 	fileStorage = s
 }
 
+// InitConfig 注入应用配置，处理器不再自行加载配置
+func InitConfig(cfg *config.AppConfig) {
+	appConfig = cfg
+	fileTypeRules = utils.NewFileTypeRules(cfg.AllowedFileTypes)
+}
+
 // GetFile 处理获取文件元数据
 func GetFile(c *gin.Context) {
 	fileID := utils.SanitizeID(c.Param("file_id"))
@@ -41,9 +52,8 @@ func GetFile(c *gin.Context) {
 		return
 	}
 
-	var file models.File
-	if err := database.DB.Where("id = ?", fileID).First(&file).Error; err != nil {
-		handleDatabaseError(c, err)
+	file, ok := requireFilePermission(c, fileID, models.PermRead)
+	if !ok {
 		return
 	}
 
@@ -57,7 +67,7 @@ func GetFile(c *gin.Context) {
 }
 
 // GetDownloadURL 处理获取下载地址
-// GetDownloadURL 修复后的函数
+// 返回指向本服务 /content 路由的签名链接，版本号、用户与过期时间均纳入签名
 func GetDownloadURL(c *gin.Context) {
 	fileID := utils.SanitizeID(c.Param("file_id"))
 	if fileID == "" {
@@ -65,10 +75,47 @@ func GetDownloadURL(c *gin.Context) {
 		return
 	}
 
-	// 从配置系统获取存储地址
-	cfg := config.LoadConfig()
+	// 下载历史版本还需要查看历史的权限
+	versionParam := c.Param("version")
+	required := models.PermDownload
+	if versionParam != "" {
+		required |= models.PermHistory
+	}
+	if _, ok := requireFilePermission(c, fileID, required); !ok {
+		return
+	}
+
+	// 未指定版本时签发 latest 链接，下载时按当前版本解析，无需查看历史的权限
+	versionStr := "latest"
+	if versionParam != "" {
+		v, err := strconv.Atoi(versionParam)
+		if err != nil || v <= 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid version number")
+			return
+		}
+		var fileVersion models.FileVersion
+		if err := database.DB.Where("id = ? AND version = ?", fileID, v).
+			First(&fileVersion).Error; err != nil {
+			handleDatabaseError(c, err)
+			return
+		}
+		versionStr = strconv.Itoa(v)
+	}
+
+	// 从配置系统获取签名参数
+	cfg := appConfig
+	userID := middleware.CurrentUserID(c)
+	expires := strconv.FormatInt(time.Now().Add(cfg.URLExpiry).Unix(), 10)
+	signature := utils.SignParts(cfg.URLSignKey, fileID, versionStr, expires, userID)
+
+	query := url.Values{}
+	query.Set("version", versionStr)
+	query.Set("uid", userID)
+	query.Set("expires", expires)
+	query.Set("signature", signature)
 	utils.SuccessResponse(c, gin.H{
-		"url": fmt.Sprintf("%s/files/%s", cfg.StorageURL, fileID),
+		"url": fmt.Sprintf("%s/v3/3rd/files/%s/content?%s",
+			cfg.BaseURL, url.PathEscape(fileID), query.Encode()),
 	})
 }
 
@@ -86,85 +133,46 @@ func GetPermissions(c *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(c, gin.H{
-		"read":        1,
-		"update":      1,
-		"download":    1,
-		"user_id":     file.CreatorID,
-		"history":     1,
-		"copy":        1,
-		"print":       1,
-		"modifier_id": file.ModifierID,
-	})
-}
-
-// PrepareUpload 处理上传准备
-func PrepareUpload(c *gin.Context) {
-	utils.SuccessResponse(c, gin.H{
-		"digest_types": []string{"sha1", "md5"},
-	})
-}
-
-// GetUploadAddress 处理获取上传地址
-// GetUploadAddress 修复后的函数
-func GetUploadAddress(c *gin.Context) {
-	var req struct {
-		Name     string            `json:"name"`
-		Size     int               `json:"size"`
-		Digest   map[string]string `json:"digest"`
-		IsManual bool              `json:"is_manual"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+	userID := middleware.CurrentUserID(c)
+	perms, err := filePermissions(database.DB, &file, userID)
+	if err != nil {
+		handleDatabaseError(c, err)
 		return
 	}
+	granted := func(bit int) int {
+		if perms&bit != 0 {
+			return 1
+		}
+		return 0
+	}
 
-	// 从配置系统获取上传地址
-	cfg := config.LoadConfig()
 	utils.SuccessResponse(c, gin.H{
-		"url":    fmt.Sprintf("%s/upload", cfg.UploadURL),
-		"method": "PUT",
-		"headers": map[string]string{
-			"Content-Type": "application/octet-stream",
-		},
+		"read":        granted(models.PermRead),
+		"update":      granted(models.PermUpdate),
+		"download":    granted(models.PermDownload),
+		"user_id":     userID,
+		"history":     granted(models.PermHistory),
+		"copy":        granted(models.PermCopy),
+		"print":       granted(models.PermPrint),
+		"comment":     granted(models.PermComment),
+		"modifier_id": file.ModifierID,
 	})
 }
 
-// UploadComplete 处理上传完成
-func UploadComplete(c *gin.Context) {
-	fileID := utils.SanitizeID(c.Param("file_id"))
-	if fileID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "无效的文件ID")
-		return
-	}
-
-	fileHeader, err := c.FormFile("file")
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "缺少文件内容")
-		return
-	}
-
-	if err := utils.ValidateFileType(fileHeader); err != nil {
-		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, err.Error())
-		return
+// errContentMissing 引用的内容已被垃圾回收清理，登记引用不能使其恢复
+var errContentMissing = errors.New("内容已被清理")
+
+// commitFileVersion 在主文件行级锁保护下为文件创建下一个版本（文件不存在时创建首个版本），
+// 并登记对内容 meta.Sha256 的引用；saveContent 在同一事务内写入内容，blobExists 为真时
+// 相同内容已在存储中，无需再次写入。任一步骤失败则整体回滚
+func commitFileVersion(fileID, userID string, meta models.FileVersion, saveContent func(version int, blobExists bool) error) (int, error) {
+	var currentVersion int
+	fileName, size := meta.Name, meta.Size
+	if meta.Sha256 != "" {
+		meta.StorageKey = storage.ObjectStorageKey(meta.Sha256)
 	}
 
-	file, err := fileHeader.Open()
-	if err != nil {
-		log.Printf("打开上传文件失败: %v", err)
-		utils.ErrorResponse(c, http.StatusInternalServerError, "文件处理失败")
-		return
-	}
-	defer file.Close()
-
-	var (
-		currentVersion int
-		currentUserID  = "user1" // 实际应从认证信息获取
-		fileName       = filepath.Base(fileHeader.Filename)
-	)
-
-	err = database.DB.Transaction(func(tx *gorm.DB) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
 		// 1. 行级锁查询主文件记录
 		var fileModel models.File
 		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
@@ -192,11 +200,12 @@ func UploadComplete(c *gin.Context) {
 					ID:         fileID,
 					Name:       fileName,
 					Version:    1,
-					Size:       int(fileHeader.Size),
+					Size:       size,
 					CreateTime: now,
 					ModifyTime: now,
-					CreatorID:  currentUserID,
-					ModifierID: currentUserID,
+					CreatorID:  userID,
+					ModifierID: userID,
+					OwnerID:    userID,
 				}
 				if err := tx.Create(&newFile).Error; err != nil {
 					return fmt.Errorf("创建主文件记录失败: %w", err)
@@ -206,14 +215,7 @@ func UploadComplete(c *gin.Context) {
 				if err := tx.Clauses(clause.OnConflict{
 					Columns:   []clause.Column{{Name: "id"}, {Name: "version"}},
 					DoNothing: true,
-				}).Create(&models.FileVersion{
-					ID:         fileID,
-					Version:    1,
-					Name:       fileName,
-					Size:       int(fileHeader.Size),
-					CreateTime: now,
-					ModifierID: currentUserID,
-				}).Error; err != nil {
+				}).Create(newVersionRecord(meta, fileID, 1, userID, now)).Error; err != nil {
 					tx.Rollback() // 强制回滚主文件记录
 					return fmt.Errorf("创建版本记录失败: %w", err)
 				}
@@ -223,7 +225,15 @@ func UploadComplete(c *gin.Context) {
 				return fmt.Errorf("查询文件失败: %w", result.Error)
 			}
 		} else {
-			// 6. 处理文件已存在的情况
+			// 6. 处理文件已存在的情况，持锁后再次确认编辑权限
+			perms, err := filePermissions(tx, &fileModel, userID)
+			if err != nil {
+				return err
+			}
+			if perms&models.PermUpdate == 0 {
+				return errPermissionDenied
+			}
+
 			// 原子递增版本号
 			if err := tx.Model(&models.File{}).
 				Where("id = ?", fileID).
@@ -241,25 +251,19 @@ func UploadComplete(c *gin.Context) {
 			currentVersion = latestFile.Version
 
 			// 8. 创建新版本记录
-			newVersion := models.FileVersion{
-				ID:         fileID,
-				Version:    currentVersion,
-				Name:       fileName,
-				Size:       int(fileHeader.Size),
-				CreateTime: time.Now().Unix(),
-				ModifierID: currentUserID,
-			}
-			if err := tx.Create(&newVersion).Error; err != nil {
+			newVersion := newVersionRecord(meta, fileID, currentVersion, userID, time.Now().Unix())
+			if err := tx.Create(newVersion).Error; err != nil {
 				return fmt.Errorf("创建版本记录失败: %w", err)
 			}
+			// 9. 旧版本由后台任务按保留策略清理
+		}
 
-			// 9. 清理旧版本（保留最近5个）
-			if currentVersion > 5 {
-				if err := tx.Where("id = ? AND version < ?",
-					fileID, currentVersion-5).
-					Delete(&models.FileVersion{}).Error; err != nil {
-					log.Printf("版本清理失败（非致命错误）: %v", err)
-				}
+		// 登记内容引用，相同内容只保存一份
+		blobExists := false
+		if meta.Sha256 != "" {
+			var err error
+			if blobExists, err = database.AcquireBlob(tx, meta.Sha256, int64(size)); err != nil {
+				return err
 			}
 		}
 
@@ -267,8 +271,8 @@ func UploadComplete(c *gin.Context) {
 		updateFields := map[string]interface{}{
 			"name":        fileName,
 			"modify_time": time.Now().Unix(),
-			"size":        int(fileHeader.Size),
-			"modifier_id": currentUserID,
+			"size":        size,
+			"modifier_id": userID,
 		}
 		if err := tx.Model(&models.File{}).
 			Where("id = ?", fileID).
@@ -277,50 +281,86 @@ func UploadComplete(c *gin.Context) {
 		}
 
 		// 11. 存储文件内容
-		if _, err := file.Seek(0, 0); err != nil {
-			return fmt.Errorf("文件指针重置失败: %w", err)
-		}
-		if err := fileStorage.SaveFile(fileID, currentVersion, fileName, file); err != nil {
+		if err := saveContent(currentVersion, blobExists); err != nil {
 			return fmt.Errorf("文件存储失败: %w", err)
 		}
 
 		return nil
 	})
 
-	if err != nil {
-		log.Printf("上传处理失败: %v", err)
-		utils.ErrorResponse(c, http.StatusInternalServerError,
-			fmt.Sprintf("上传处理失败: %v", err))
-		return
-	}
+	return currentVersion, err
+}
 
-	utils.SuccessResponse(c, gin.H{
-		"message":   "上传完成",
-		"version":   currentVersion,
-		"file_id":   fileID,
-		"file_name": fileName,
-	})
+// newVersionRecord 以 meta 中的内容元数据生成版本记录
+func newVersionRecord(meta models.FileVersion, fileID string, version int, userID string, now int64) *models.FileVersion {
+	meta.ID = fileID
+	meta.Version = version
+	meta.CreateTime = now
+	meta.ModifierID = userID
+	return &meta
+}
+
+// openVersionContent 按版本清单中的存储键打开版本内容，没有清单的版本视为内容不存在
+func openVersionContent(version *models.FileVersion) (io.ReadCloser, error) {
+	if version.StorageKey == "" {
+		return nil, storage.ErrNotFound
+	}
+	return fileStorage.GetFile(version.StorageKey)
 }
 
 // 新增文件下载路由处理
+// 仅接受 GetDownloadURL 签发的未过期签名链接，并按签发用户的当前权限再次校验
 func DownloadFile(c *gin.Context) {
 	fileID := utils.SanitizeID(c.Param("file_id"))
 	versionStr := c.DefaultQuery("version", "latest")
+	userID := c.Query("uid")
+
+	// 校验链接签名与有效期
+	cfg := appConfig
+	expiresStr := c.Query("expires")
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || !utils.VerifyParts(cfg.URLSignKey, c.Query("signature"), fileID, versionStr, expiresStr, userID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "下载链接签名无效")
+		return
+	}
+	if time.Now().Unix() > expires {
+		utils.ErrorResponse(c, http.StatusForbidden, "下载链接已过期")
+		return
+	}
 
 	var (
 		version  int
 		fileName string
 	)
+	fileVersion := &models.FileVersion{ID: fileID}
+
+	// 权限可能在链接签发后被撤销
+	var file models.File
+	if err := database.DB.Where("id = ?", fileID).First(&file).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	perms, err := filePermissions(database.DB, &file, userID)
+	if err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	if perms&models.PermDownload == 0 || (versionStr != "latest" && perms&models.PermHistory == 0) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Permission denied")
+		return
+	}
 
 	// 获取版本信息
 	if versionStr == "latest" {
-		var file models.File
-		if err := database.DB.Where("id = ?", fileID).First(&file).Error; err != nil {
+		version = file.Version
+		fileName = file.Name
+
+		// 当前版本的内容位置记录在版本清单中
+		if err := database.DB.Where("id = ? AND version = ?", fileID, version).
+			First(fileVersion).Error; err != nil {
 			handleDatabaseError(c, err)
 			return
 		}
-		version = file.Version
-		fileName = file.Name
 	} else {
 		v, err := strconv.Atoi(versionStr)
 		if err != nil || v <= 0 {
@@ -329,9 +369,8 @@ func DownloadFile(c *gin.Context) {
 		}
 		version = v
 
-		var fileVersion models.FileVersion
 		if err := database.DB.Where("id = ? AND version = ?", fileID, version).
-			First(&fileVersion).Error; err != nil {
+			First(fileVersion).Error; err != nil {
 			handleDatabaseError(c, err)
 			return
 		}
@@ -339,9 +378,9 @@ func DownloadFile(c *gin.Context) {
 	}
 
 	// 获取文件流
-	reader, err := fileStorage.GetFile(fileID, version)
+	reader, err := openVersionContent(fileVersion)
 	if err != nil {
-		if os.IsNotExist(err) { // 需要导入 "os"
+		if errors.Is(err, storage.ErrNotFound) {
 			utils.ErrorResponse(c, http.StatusNotFound, "文件内容不存在")
 		} else {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "文件访问失败")
@@ -351,7 +390,11 @@ func DownloadFile(c *gin.Context) {
 	defer reader.Close()
 
 	// 设置响应头（支持中文文件名）
-	c.Header("Content-Type", "application/octet-stream")
+	contentType := fileVersion.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
 	c.Header("Content-Disposition",
 		fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(fileName))) // 需要导入 "net/url"
 
@@ -379,6 +422,10 @@ func RenameFile(c *gin.Context) {
 		return
 	}
 
+	if _, ok := requireFilePermission(c, fileID, models.PermUpdate); !ok {
+		return
+	}
+
 	var req struct {
 		Name string `json:"name"`
 	}
@@ -390,7 +437,11 @@ func RenameFile(c *gin.Context) {
 
 	if err := database.DB.Model(&models.File{}).
 		Where("id = ?", fileID).
-		Update("name", req.Name).Error; err != nil {
+		Updates(map[string]interface{}{
+			"name":        req.Name,
+			"modify_time": time.Now().Unix(),
+			"modifier_id": middleware.CurrentUserID(c),
+		}).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update filename")
 		return
 	}
@@ -398,14 +449,42 @@ func RenameFile(c *gin.Context) {
 	utils.SuccessResponse(c, nil)
 }
 
-// ListVersions 列出文件版本
+// ListVersions 列出文件版本，可按 pinned=true|false、labeled=true|false 与 label（精确匹配）筛选
 func ListVersions(c *gin.Context) {
 	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermHistory); !ok {
+		return
+	}
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
+	query := database.DB.Where("id = ?", fileID)
+	if raw := c.Query("pinned"); raw != "" {
+		pinned, err := strconv.ParseBool(raw)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid pinned filter")
+			return
+		}
+		query = query.Where("pinned = ?", pinned)
+	}
+	if raw := c.Query("labeled"); raw != "" {
+		labeled, err := strconv.ParseBool(raw)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid labeled filter")
+			return
+		}
+		if labeled {
+			query = query.Where("label IS NOT NULL AND label <> ''")
+		} else {
+			query = query.Where("label IS NULL OR label = ''")
+		}
+	}
+	if label := c.Query("label"); label != "" {
+		query = query.Where("label = ?", label)
+	}
+
 	var versions []models.FileVersion
-	if err := database.DB.Where("id = ?", fileID).
+	if err := query.
 		Order("version DESC").
 		Offset(offset).
 		Limit(limit).
@@ -420,6 +499,9 @@ func ListVersions(c *gin.Context) {
 // GetVersion 获取特定版本
 func GetVersion(c *gin.Context) {
 	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermHistory); !ok {
+		return
+	}
 	version, err := strconv.Atoi(c.Param("version"))
 	if err != nil || version <= 0 {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid version number")
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"weboffice/internal/config"
+	"weboffice/internal/database"
+	"weboffice/internal/middleware"
+	"weboffice/internal/migrations"
+	"weboffice/internal/models"
+	"weboffice/internal/scanner"
+	"weboffice/internal/storage"
+	"weboffice/internal/utils"
+)
+
+const testBaseURL = "http://weboffice.test"
+
+// testEnv 处理器测试环境：临时 SQLite 数据库、内存存储，以及不校验回调签名、
+// 按固定令牌表解析用户的路由
+type testEnv struct {
+	t      *testing.T
+	db     *gorm.DB
+	store  *storage.MemoryStorage
+	cfg    *config.AppConfig
+	router *gin.Engine
+}
+
+// 测试令牌 → 用户ID；ghost 不在 users 表中
+var testTokens = middleware.StaticResolver{
+	"tok-alice": "alice",
+	"tok-bob":   "bob",
+	"tok-ghost": "ghost",
+}
+
+// newTestEnv 创建测试环境并替换处理器使用的全局依赖，测试结束后恢复。
+// configure 在注入配置前调整默认配置
+func newTestEnv(t *testing.T, configure func(cfg *config.AppConfig)) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.Connect(&config.DBConfig{
+		Driver: database.DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "weboffice.db"),
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	store := storage.NewMemoryStorage()
+	if _, err := migrations.NewRunner(db, store).Up(0); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	for _, id := range []string{"alice", "bob"} {
+		if err := db.Create(&models.User{ID: id, Name: id}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.BaseURL = testBaseURL
+	cfg.URLSignKey = "test-sign-key"
+	cfg.CallbackAuth.Enabled = false
+	cfg.StorageDriver = "memory"
+	if configure != nil {
+		configure(cfg)
+	}
+
+	oldDB, oldStorage, oldConfig, oldRules, oldScanner := database.DB, fileStorage, appConfig, fileTypeRules, contentScanner
+	t.Cleanup(func() {
+		database.DB, fileStorage, appConfig, fileTypeRules, contentScanner = oldDB, oldStorage, oldConfig, oldRules, oldScanner
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	database.DB = db
+	InitFileStorage(store)
+	InitConfig(cfg)
+	contentScanner = scanner.Scanner(nil)
+
+	resolver := &middleware.ShareResolver{Next: testTokens, DB: db, SignKey: cfg.URLSignKey}
+	r := gin.New()
+	files := r.Group("/v3/3rd/files", middleware.Authenticate(resolver))
+	files.GET("/:file_id/download", GetDownloadURL)
+	files.GET("/:file_id/versions/:version/download", GetDownloadURL)
+	files.POST("/:file_id/versions/:version/restore", RestoreVersion)
+	files.GET("/:file_id/upload/prepare", PrepareUpload)
+	files.POST("/:file_id/upload/address", GetUploadAddress)
+	files.POST("/:file_id/upload/complete", UploadComplete)
+	objects := r.Group("/v3/3rd/object", middleware.Authenticate(resolver))
+	objects.PUT("/:key", UploadObject)
+	objects.POST("/copy", CopyObject)
+	r.GET("/v3/3rd/files/:file_id/content", DownloadFile)
+	r.PUT("/v3/3rd/upload/:token", UploadContent)
+
+	return &testEnv{t: t, db: db, store: store, cfg: cfg, router: r}
+}
+
+// do 以 token 对应的用户发送请求，body 为 nil 以外的值时编码为 JSON
+func (e *testEnv) do(method, target, token string, body interface{}) *httptest.ResponseRecorder {
+	e.t.Helper()
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, strings.TrimPrefix(target, testBaseURL), reader)
+	if token != "" {
+		req.Header.Set(middleware.HeaderToken, token)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect 校验状态码并返回响应中的 data 字段
+func (e *testEnv) expect(w *httptest.ResponseRecorder, code int) map[string]interface{} {
+	e.t.Helper()
+	if w.Code != code {
+		e.t.Fatalf("状态码 %d，期望 %d: %s", w.Code, code, w.Body)
+	}
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			e.t.Fatalf("解析响应失败: %v: %s", err, w.Body)
+		}
+	}
+	return resp.Data
+}
+
+func sha1Digest(content []byte) map[string]string {
+	sum := sha1.Sum(content)
+	return map[string]string{"sha1": hex.EncodeToString(sum[:])}
+}
+
+// address 依次调用上传准备与获取上传地址接口，返回上传端点路径
+func (e *testEnv) address(token, fileID, name string, size int, digest map[string]string) string {
+	e.t.Helper()
+	base := "/v3/3rd/files/" + fileID + "/upload"
+	e.expect(e.do(http.MethodGet, base+"/prepare", token, nil), http.StatusOK)
+	data := e.expect(e.do(http.MethodPost, base+"/address", token, gin.H{
+		"name": name, "size": size, "digest": digest,
+	}), http.StatusOK)
+	return strings.TrimPrefix(data["url"].(string), testBaseURL)
+}
+
+// complete 提交上传完成回执，statusCode 为 WebOffice 上传到存储端点时得到的状态码
+func (e *testEnv) complete(token, fileID, name string, content []byte, statusCode int) *httptest.ResponseRecorder {
+	e.t.Helper()
+	return e.do(http.MethodPost, "/v3/3rd/files/"+fileID+"/upload/complete", token, gin.H{
+		"request":  gin.H{"name": name, "size": len(content), "digest": sha1Digest(content)},
+		"response": gin.H{"status_code": statusCode},
+	})
+}
+
+// upload 走完 prepare → address → PUT → complete 上传流程，返回最后一步的响应
+func (e *testEnv) upload(token, fileID, name string, content []byte) *httptest.ResponseRecorder {
+	e.t.Helper()
+	target := e.address(token, fileID, name, len(content), sha1Digest(content))
+	if w := e.do(http.MethodPut, target, "", content); w.Code != http.StatusOK {
+		return w
+	}
+	return e.complete(token, fileID, name, content, http.StatusOK)
+}
+
+// grant 为用户授予文件权限
+func (e *testEnv) grant(fileID, userID string, perms int) {
+	e.t.Helper()
+	if err := e.db.Create(&models.FileACL{
+		FileID: fileID, SubjectType: models.SubjectUser, SubjectID: userID,
+		Permissions: perms, GrantedBy: "alice", CreateTime: time.Now().Unix(),
+	}).Error; err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// signedContentURL 按 DownloadFile 的签名规则构造下载链接
+func (e *testEnv) signedContentURL(fileID, version, userID string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{}
+	query.Set("version", version)
+	query.Set("uid", userID)
+	query.Set("expires", exp)
+	query.Set("signature", utils.SignParts(e.cfg.URLSignKey, fileID, version, exp, userID))
+	return "/v3/3rd/files/" + fileID + "/content?" + query.Encode()
+}
+
+func TestDownloadURL(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("第一版")), http.StatusOK)
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("第二版")), http.StatusOK)
+	env.grant("f1", "bob", models.PermRead|models.PermDownload)
+
+	download := func(token, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		data := env.expect(env.do(http.MethodGet, target, token, nil), http.StatusOK)
+		return env.do(http.MethodGet, data["url"].(string), "", nil)
+	}
+
+	// 最新版本
+	w := download("tok-alice", "/v3/3rd/files/f1/download")
+	if w.Code != http.StatusOK || w.Body.String() != "第二版" {
+		t.Fatalf("下载最新版本返回 %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename*=UTF-8''notes.txt" {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	// 历史版本
+	if w := download("tok-alice", "/v3/3rd/files/f1/versions/1/download"); w.Code != http.StatusOK || w.Body.String() != "第一版" {
+		t.Fatalf("下载历史版本返回 %d: %s", w.Code, w.Body)
+	}
+
+	// 没有查看历史权限的用户可以下载最新版本，但不能获取或使用历史版本链接
+	if w := download("tok-bob", "/v3/3rd/files/f1/download"); w.Code != http.StatusOK || w.Body.String() != "第二版" {
+		t.Fatalf("bob 下载最新版本返回 %d: %s", w.Code, w.Body)
+	}
+	env.expect(env.do(http.MethodGet, "/v3/3rd/files/f1/versions/1/download", "tok-bob", nil), http.StatusForbidden)
+	env.expect(env.do(http.MethodGet, env.signedContentURL("f1", "1", "bob", time.Now().Add(time.Minute)), "", nil), http.StatusForbidden)
+
+	// 没有任何权限的用户与不存在的版本
+	env.expect(env.do(http.MethodGet, "/v3/3rd/files/f1/download", "tok-ghost", nil), http.StatusForbidden)
+	env.expect(env.do(http.MethodGet, "/v3/3rd/files/f1/versions/9/download", "tok-alice", nil), http.StatusNotFound)
+	env.expect(env.do(http.MethodGet, "/v3/3rd/files/f1/versions/0/download", "tok-alice", nil), http.StatusBadRequest)
+}
+
+func TestDownloadFileSignature(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("内容")), http.StatusOK)
+	env.grant("f1", "bob", models.PermRead|models.PermDownload|models.PermHistory)
+	valid := env.signedContentURL("f1", "latest", "bob", time.Now().Add(time.Minute))
+
+	tamper := func(key, value string) string {
+		u, _ := url.Parse(valid)
+		q := u.Query()
+		q.Set(key, value)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		wantMsg  string
+	}{
+		{"valid", valid, http.StatusOK, ""},
+		{"expired", env.signedContentURL("f1", "latest", "bob", time.Now().Add(-time.Second)), http.StatusForbidden, "下载链接已过期"},
+		{"extended expiry", tamper("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)), http.StatusForbidden, "签名无效"},
+		{"other version", tamper("version", "1"), http.StatusForbidden, "签名无效"},
+		{"other user", tamper("uid", "alice"), http.StatusForbidden, "签名无效"},
+		{"corrupted signature", strings.Replace(
+			env.signedContentURL("f1", "latest", "bob", time.Now().Add(time.Minute)), "signature=", "signature=00", 1),
+			http.StatusForbidden, "签名无效"},
+		{"missing signature", "/v3/3rd/files/f1/content?version=latest&uid=bob", http.StatusForbidden, "签名无效"},
+		{"user without access", env.signedContentURL("f1", "latest", "ghost", time.Now().Add(time.Minute)), http.StatusForbidden, "Permission denied"},
+		{"missing version", env.signedContentURL("f1", "7", "alice", time.Now().Add(time.Minute)), http.StatusNotFound, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := env.do(http.MethodGet, tc.target, "", nil)
+			if w.Code != tc.wantCode || !strings.Contains(w.Body.String(), tc.wantMsg) {
+				t.Fatalf("返回 %d %s，期望 %d 且包含 %q", w.Code, w.Body, tc.wantCode, tc.wantMsg)
+			}
+		})
+	}
+
+	// 链接签发后撤销权限立即生效
+	env.db.Where("file_id = ? AND subject_id = ?", "f1", "bob").Delete(&models.FileACL{})
+	env.expect(env.do(http.MethodGet, valid, "", nil), http.StatusForbidden)
+}
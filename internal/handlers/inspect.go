@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"weboffice/internal/config"
+	"weboffice/internal/models"
+	"weboffice/internal/sniff"
+)
+
+// 处理方式的严重程度，多种主动内容同时存在时取最严格者
+var actionSeverity = map[string]int{
+	config.ActionAllow:      0,
+	config.ActionStrip:      1,
+	config.ActionQuarantine: 2,
+	config.ActionReject:     3,
+}
+
+// contentInspection 上传内容的主动内容检查结果
+type contentInspection struct {
+	models.ActiveContent
+	action  string   // 综合处理方式
+	reason  string   // 拒绝或隔离的原因
+	details []string // 命中的部件或条目
+}
+
+// inspectActiveContent 检测宏、嵌入对象与外部链接，并按 active_content 配置决定处理方式；
+// strip 仅适用于 OOXML 中的宏，无法移除时按 quarantine 处理
+func inspectActiveContent(r io.ReaderAt, size int64, kind sniff.Kind) (*contentInspection, error) {
+	found, err := sniff.InspectActiveContent(r, size, kind)
+	if err != nil {
+		return nil, fmt.Errorf("检测主动内容失败: %w", err)
+	}
+
+	policy := appConfig.ActiveContent
+	result := &contentInspection{action: config.ActionAllow, details: found.Details}
+	var blocked []string
+	apply := func(hit bool, action, label string) {
+		if !hit {
+			return
+		}
+		if action == config.ActionStrip && kind != sniff.KindOOXML {
+			action = config.ActionQuarantine
+		}
+		if actionSeverity[action] > actionSeverity[result.action] {
+			result.action = action
+		}
+		if action == config.ActionQuarantine || action == config.ActionReject {
+			blocked = append(blocked, label)
+		}
+	}
+	apply(found.Macros, policy.Macros, "宏")
+	apply(found.EmbeddedObjects, policy.EmbeddedObjects, "嵌入对象")
+	apply(found.ExternalLinks, policy.ExternalLinks, "外部链接")
+
+	result.ActiveContent = models.ActiveContent{
+		HasMacros:          found.Macros,
+		HasEmbeddedObjects: found.EmbeddedObjects,
+		HasExternalLinks:   found.ExternalLinks,
+	}
+	if found.Any() {
+		result.ActiveContentAction = result.action
+	}
+	if len(blocked) > 0 {
+		result.reason = "文件包含" + strings.Join(blocked, "、")
+	}
+	return result, nil
+}
+
+// stripMacros 将移除宏后的内容写入新的临时文件，调用方负责关闭并删除
+func stripMacros(r io.ReaderAt, size int64) (*os.File, int64, error) {
+	out, err := os.CreateTemp("", "weboffice-stripped-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	if _, err := sniff.StripMacros(r, size, out); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return nil, 0, fmt.Errorf("移除宏失败: %w", err)
+	}
+	stripped, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return nil, 0, err
+	}
+	return out, stripped, nil
+}
+
+// activeContentFields 返回写入上传会话的主动内容字段
+func activeContentFields(ac models.ActiveContent) map[string]interface{} {
+	return map[string]interface{}{
+		"has_macros":            ac.HasMacros,
+		"has_embedded_objects":  ac.HasEmbeddedObjects,
+		"has_external_links":    ac.HasExternalLinks,
+		"active_content_action": ac.ActiveContentAction,
+	}
+}
@@ -2,39 +2,130 @@ package handlers
 
 import (
     "crypto/md5"
+    "crypto/sha1"
+    "crypto/sha256"
     "encoding/hex"
+    "errors"
     "fmt"
+    "io"
+    "log"
     "net/http"
+    "net/url"
+    "os"
+    "strconv"
     "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
 
-    "weboffice/internal/config"
     "weboffice/internal/database"
     "weboffice/internal/models"
+    "weboffice/internal/storage"
     "weboffice/internal/utils"
 )
 
 // UploadObject 处理附件上传
+// 请求体先落地到临时文件并计算摘要、完成病毒扫描，再按 SHA-256 写入存储后端（内容相同时共享），
+// 表中只记录元信息
 func UploadObject(c *gin.Context) {
     key := c.Param("key")
-    data, err := c.GetRawData()
+
+    // 附件大小上限与回调请求体上限一致，未启用签名校验时同样生效，超出时不再继续写入临时文件
+    if appConfig != nil && appConfig.CallbackAuth != nil && appConfig.CallbackAuth.MaxBodySize > 0 {
+        limit := appConfig.CallbackAuth.MaxBodySize
+        if c.Request.ContentLength > limit {
+            utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Object too large")
+            return
+        }
+        c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+    }
+
+    spool, err := os.CreateTemp("", "weboffice-object-*")
+    if err != nil {
+        log.Printf("创建附件临时文件失败: %v", err)
+        utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to store object")
+        return
+    }
+    defer os.Remove(spool.Name())
+    defer spool.Close()
+
+    md5Hash, sha1Hash, sha256Hash := md5.New(), sha1.New(), sha256.New()
+    size, err := io.Copy(io.MultiWriter(spool, md5Hash, sha1Hash, sha256Hash), c.Request.Body)
     if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Object too large")
+            return
+        }
         utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read object data")
         return
     }
+    digest := hex.EncodeToString(md5Hash.Sum(nil))
 
-    hash := md5.Sum(data)
-    digest := hex.EncodeToString(hash[:])
+    head := make([]byte, 512)
+    n, _ := spool.ReadAt(head, 0)
+    contentType := objectContentType(c.ContentType(), head[:n])
 
+    // 病毒扫描：感染的附件移入隔离区，不写入存储与数据库
+    if _, err := spool.Seek(0, io.SeekStart); err != nil {
+        utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to store object")
+        return
+    }
+    signature, err := scanContent(c.Request.Context(), spool, "附件 "+key)
+    if err != nil {
+        utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error()+"，请稍后重试")
+        return
+    }
+    if signature != "" {
+        // 附件键由调用方提供，隔离区使用随机键避免路径穿越
+        quarantineKey := "attachment-" + uuid.New().String()
+        _, err := spool.Seek(0, io.SeekStart)
+        if err == nil {
+            err = fileStorage.Quarantine(quarantineKey, spool)
+        }
+        if err != nil {
+            log.Printf("隔离附件 %s 失败: %v", key, err)
+            utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to store object")
+            return
+        }
+        log.Printf("附件 %s 已隔离为 %s: 检测到病毒 %s", key, quarantineKey, signature)
+        utils.ErrorResponse(c, http.StatusUnprocessableEntity, "检测到病毒 "+signature+"，已隔离待审核")
+        return
+    }
+
+    sum := hex.EncodeToString(sha256Hash.Sum(nil))
     attachment := models.Attachment{
-        Key:       key,
-        Data:      data,
-        CreatedAt: time.Now().Unix(),
+        Key:         key,
+        Size:        size,
+        Md5:         digest,
+        Sha1:        hex.EncodeToString(sha1Hash.Sum(nil)),
+        ContentType: contentType,
+        Sha256:      sum,
+        StorageKey:  sum,
+        CreatedAt:   time.Now().Unix(),
     }
 
-    if err := database.DB.Create(&attachment).Error; err != nil {
+    err = database.DB.Transaction(func(tx *gorm.DB) error {
+        exists, err := database.AcquireBlob(tx, sum, size)
+        if err != nil {
+            return err
+        }
+        if err := tx.Create(&attachment).Error; err != nil {
+            return err
+        }
+        if exists {
+            return nil
+        }
+        if _, err := spool.Seek(0, io.SeekStart); err != nil {
+            return err
+        }
+        _, err = fileStorage.PutObject(sum, spool)
+        return err
+    })
+    if err != nil {
+        log.Printf("保存附件 %s 失败: %v", key, err)
         utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to store object")
         return
     }
@@ -44,16 +135,109 @@ func UploadObject(c *gin.Context) {
     })
 }
 
+// objectContentType 优先使用请求声明的内容类型，未声明或为通用二进制类型时按内容识别
+func objectContentType(declared string, head []byte) string {
+    if declared != "" && declared != "application/octet-stream" {
+        return declared
+    }
+    return http.DetectContentType(head)
+}
+
 // GetObjectURL 处理获取附件URL
+// 返回指向 DownloadObject 的签名链接，有效期为 url_expiry
 func GetObjectURL(c *gin.Context) {
     key := c.Param("key")
-    cfg := config.LoadConfig()
+    cfg := appConfig
+
+    var attachment models.Attachment
+    if err := database.DB.Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key}).
+        First(&attachment).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            utils.ErrorResponse(c, http.StatusNotFound, "Object not found")
+        } else {
+            handleDatabaseError(c, err)
+        }
+        return
+    }
+
+    expires := strconv.FormatInt(time.Now().Add(cfg.URLExpiry).Unix(), 10)
+    query := url.Values{}
+    query.Set("expires", expires)
+    query.Set("signature", utils.SignParts(cfg.URLSignKey, "object", key, expires))
 
     utils.SuccessResponse(c, gin.H{
-        "url": fmt.Sprintf("%s/objects/%s", cfg.StorageURL, key),
+        "url": fmt.Sprintf("%s/v3/3rd/object/%s/content?%s", cfg.BaseURL, url.PathEscape(key), query.Encode()),
     })
 }
 
+// DownloadObject 处理附件下载
+// 仅接受 GetObjectURL 签发的未过期签名链接，支持 Range、条件请求与缓存
+func DownloadObject(c *gin.Context) {
+    key := c.Param("key")
+
+    cfg := appConfig
+    expiresStr := c.Query("expires")
+    expires, err := strconv.ParseInt(expiresStr, 10, 64)
+    if err != nil || !utils.VerifyParts(cfg.URLSignKey, c.Query("signature"), "object", key, expiresStr) {
+        utils.ErrorResponse(c, http.StatusForbidden, "附件链接签名无效")
+        return
+    }
+    remaining := expires - time.Now().Unix()
+    if remaining < 0 {
+        utils.ErrorResponse(c, http.StatusForbidden, "附件链接已过期")
+        return
+    }
+
+    var attachment models.Attachment
+    if err := database.DB.Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key}).
+        First(&attachment).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            utils.ErrorResponse(c, http.StatusNotFound, "Object not found")
+        } else {
+            handleDatabaseError(c, err)
+        }
+        return
+    }
+
+    reader, err := fileStorage.GetObject(attachment.StorageKey)
+    if err != nil {
+        if errors.Is(err, storage.ErrNotFound) {
+            utils.ErrorResponse(c, http.StatusNotFound, "附件内容不存在")
+        } else {
+            log.Printf("读取附件 %s 失败: %v", key, err)
+            utils.ErrorResponse(c, http.StatusInternalServerError, "附件访问失败")
+        }
+        return
+    }
+    defer reader.Close()
+
+    contentType := attachment.ContentType
+    if contentType == "" {
+        contentType = "application/octet-stream"
+    }
+    header := c.Writer.Header()
+    header.Set("Content-Type", contentType)
+    header.Set("ETag", `"`+attachment.Md5+`"`)
+    // 附件内容写入后不再变化，可在链接有效期内缓存
+    header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", remaining))
+    // 附件内容类型由上传方声明，禁止嗅探并以沙箱方式渲染，避免在本域执行脚本
+    header.Set("X-Content-Type-Options", "nosniff")
+    header.Set("Content-Security-Policy", "sandbox")
+
+    modTime := time.Unix(attachment.CreatedAt, 0)
+    if rs, ok := reader.(io.ReadSeeker); ok {
+        http.ServeContent(c.Writer, c.Request, "", modTime, rs)
+        return
+    }
+    header.Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+    if c.Request.Method == http.MethodHead {
+        return
+    }
+    if _, err := io.Copy(c.Writer, reader); err != nil {
+        log.Printf("附件传输中断: %v", err)
+    }
+}
+
 // CopyObject 处理对象复制
 func CopyObject(c *gin.Context) {
     var req struct {
@@ -65,26 +249,37 @@ func CopyObject(c *gin.Context) {
         return
     }
 
+    // 复制仅新增附件记录并增加内容引用，不复制存储中的数据
     err := database.DB.Transaction(func(tx *gorm.DB) error {
         for srcKey, dstKey := range req.KeyDict {
             var src models.Attachment
-            if err := tx.Where("key = ?", srcKey).First(&src).Error; err != nil {
+            if err := tx.Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: srcKey}).First(&src).Error; err != nil {
                 return fmt.Errorf("source object %s not found", srcKey)
             }
 
-            dst := models.Attachment{
-                Key:       dstKey,
-                Data:      src.Data,
-                CreatedAt: time.Now().Unix(),
-            }
+            dst := src
+            dst.Key = dstKey
+            dst.CreatedAt = time.Now().Unix()
 
             if err := tx.Create(&dst).Error; err != nil {
                 return err
             }
+            // 源附件的内容可能已被垃圾回收清理，此时不能凭空登记引用
+            exists, err := database.AcquireBlob(tx, src.Sha256, src.Size)
+            if err != nil {
+                return err
+            }
+            if !exists {
+                return fmt.Errorf("source object %s: %w", srcKey, errContentMissing)
+            }
         }
         return nil
     })
 
+    if errors.Is(err, errContentMissing) {
+        utils.ErrorResponse(c, http.StatusConflict, "Failed to copy objects: "+err.Error())
+        return
+    }
     if err != nil {
         utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to copy objects: "+err.Error())
         return
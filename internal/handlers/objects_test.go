@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"weboffice/internal/models"
+)
+
+func (e *testEnv) attachmentCount(key string) int64 {
+	var count int64
+	e.db.Model(&models.Attachment{}).Where("`key` = ?", key).Count(&count)
+	return count
+}
+
+func TestCopyObject(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.expect(env.do(http.MethodPut, "/v3/3rd/object/a", "tok-alice", []byte("attachment")), http.StatusOK)
+	var src models.Attachment
+	if err := env.db.Where("`key` = ?", "a").First(&src).Error; err != nil {
+		t.Fatalf("查询附件: %v", err)
+	}
+
+	env.expect(env.do(http.MethodPost, "/v3/3rd/object/copy", "tok-alice",
+		map[string]interface{}{"key_dict": map[string]string{"a": "b"}}), http.StatusOK)
+	var dst models.Attachment
+	if err := env.db.Where("`key` = ?", "b").First(&dst).Error; err != nil {
+		t.Fatalf("查询复制的附件: %v", err)
+	}
+	if dst.Sha256 != src.Sha256 || dst.StorageKey != src.StorageKey {
+		t.Errorf("复制的附件 %+v", dst)
+	}
+	var blob models.Blob
+	env.db.Where("sha256 = ?", src.Sha256).First(&blob)
+	if blob.RefCount != 2 {
+		t.Errorf("Blob 引用计数 %d，期望 2", blob.RefCount)
+	}
+
+	// 源附件的 Blob 已被回收时拒绝复制，整批复制回滚
+	env.db.Where("sha256 = ?", src.Sha256).Delete(&models.Blob{})
+	w := env.do(http.MethodPost, "/v3/3rd/object/copy", "tok-alice",
+		map[string]interface{}{"key_dict": map[string]string{"a": "c"}})
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "source object a") {
+		t.Fatalf("返回 %d: %s", w.Code, w.Body)
+	}
+	if env.attachmentCount("c") != 0 {
+		t.Error("复制失败后不应保留目标附件")
+	}
+	var blobs int64
+	env.db.Model(&models.Blob{}).Where("sha256 = ?", src.Sha256).Count(&blobs)
+	if blobs != 0 {
+		t.Error("复制失败后不应凭空登记 Blob")
+	}
+}
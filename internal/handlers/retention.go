@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weboffice/internal/config"
+	"weboffice/internal/database"
+	"weboffice/internal/middleware"
+	"weboffice/internal/models"
+	"weboffice/internal/retention"
+	"weboffice/internal/utils"
+)
+
+// GetFileRetention 查询文件生效的版本保留策略（管理接口）
+func GetFileRetention(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	policy, custom, err := retention.PolicyFor(database.DB, fileID, *appConfig.Retention.Default)
+	if err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	source := "default"
+	if custom {
+		source = "file"
+	}
+	utils.SuccessResponse(c, gin.H{
+		"policy": policy,
+		"source": source,
+	})
+}
+
+// SetFileRetention 为文件设置单独的版本保留策略，各项均为 0 表示不清理（管理接口）
+func SetFileRetention(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	var policy config.RetentionPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Retention values must not be negative")
+		return
+	}
+
+	record := models.FileRetention{
+		FileID:     fileID,
+		KeepLast:   policy.KeepLast,
+		KeepDays:   policy.KeepDays,
+		KeepDaily:  policy.KeepDaily,
+		KeepWeekly: policy.KeepWeekly,
+		UpdatedBy:  middleware.CurrentUserID(c),
+		UpdateTime: time.Now().Unix(),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"keep_last", "keep_days", "keep_daily", "keep_weekly", "updated_by", "update_time"}),
+	}).Create(&record).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"policy": policy,
+		"source": "file",
+	})
+}
+
+// DeleteFileRetention 删除文件单独的版本保留策略，恢复使用默认策略（管理接口）
+func DeleteFileRetention(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	result := database.DB.Where("file_id = ?", fileID).Delete(&models.FileRetention{})
+	if result.Error != nil {
+		handleDatabaseError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		handleDatabaseError(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"weboffice/internal/scanner"
+)
+
+// contentScanner 病毒扫描器，为 nil 时不扫描
+var contentScanner scanner.Scanner
+
+// errScanUnavailable 扫描未能完成且配置为拒绝（fail_open 为 false）
+var errScanUnavailable = errors.New("病毒扫描服务不可用")
+
+// InitScanner 注入病毒扫描器
+func InitScanner(s scanner.Scanner) {
+	contentScanner = s
+}
+
+// scanContent 扫描内容，返回命中的病毒名称，未感染时为空；
+// 扫描失败时按 scanner.fail_open 配置放行或返回 errScanUnavailable
+func scanContent(ctx context.Context, r io.Reader, subject string) (string, error) {
+	if contentScanner == nil {
+		return "", nil
+	}
+
+	start := time.Now()
+	result, err := contentScanner.Scan(ctx, r)
+	if err != nil {
+		if appConfig.Scanner.FailOpen {
+			log.Printf("%s 扫描失败，按 fail_open 放行: %v", subject, err)
+			return "", nil
+		}
+		log.Printf("%s 扫描失败: %v", subject, err)
+		return "", errScanUnavailable
+	}
+	if result.Infected {
+		log.Printf("%s 扫描发现病毒 %s，耗时 %v", subject, result.Signature, time.Since(start))
+		return result.Signature, nil
+	}
+	return "", nil
+}
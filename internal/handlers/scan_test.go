@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"weboffice/internal/config"
+	"weboffice/internal/scanner"
+)
+
+// closingClamd 接受连接后立即关闭的 clamd 替身，模拟扫描未能完成
+func closingClamd(t *testing.T) *scanner.Clamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return scanner.NewClamd("tcp", listener.Addr().String(), time.Second)
+}
+
+func TestScanContentFailurePolicy(t *testing.T) {
+	defer func(s scanner.Scanner, cfg *config.AppConfig) {
+		contentScanner, appConfig = s, cfg
+	}(contentScanner, appConfig)
+
+	contentScanner = closingClamd(t)
+	for _, tc := range []struct {
+		name     string
+		failOpen bool
+		wantErr  error
+	}{
+		{name: "fail open", failOpen: true},
+		{name: "fail closed", failOpen: false, wantErr: errScanUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			appConfig = &config.AppConfig{Scanner: &config.ScannerConfig{FailOpen: tc.failOpen}}
+			signature, err := scanContent(context.Background(), strings.NewReader("content"), "测试")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("scanContent 返回错误 %v，期望 %v", err, tc.wantErr)
+			}
+			if signature != "" {
+				t.Errorf("scanContent 返回病毒名称 %q", signature)
+			}
+		})
+	}
+}
+
+func TestScanContentDisabled(t *testing.T) {
+	defer func(s scanner.Scanner) { contentScanner = s }(contentScanner)
+
+	contentScanner = nil
+	if signature, err := scanContent(context.Background(), strings.NewReader("content"), "测试"); signature != "" || err != nil {
+		t.Fatalf("未启用扫描时 scanContent 返回 (%q, %v)", signature, err)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"weboffice/internal/database"
+	"weboffice/internal/middleware"
+	"weboffice/internal/models"
+	"weboffice/internal/utils"
+)
+
+// shareLinkView 管理接口返回的分享链接信息
+func shareLinkView(link *models.ShareLink, now int64) gin.H {
+	return gin.H{
+		"id":           link.ID,
+		"file_id":      link.FileID,
+		"token":        link.Token,
+		"scope":        link.Scope,
+		"has_password": link.PasswordHash != "",
+		"max_uses":     link.MaxUses,
+		"use_count":    link.UseCount,
+		"expire_time":  link.ExpireTime,
+		"creator_id":   link.CreatorID,
+		"create_time":  link.CreateTime,
+		"revoke_time":  link.RevokeTime,
+		"active":       link.Active(now),
+	}
+}
+
+// CreateShareLink 为文件创建分享链接（管理接口）
+func CreateShareLink(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	var req struct {
+		Scope     string `json:"scope"`
+		Password  string `json:"password"`
+		ExpiresIn int64  `json:"expires_in"` // 有效期（秒），0 表示永不过期
+		MaxUses   int    `json:"max_uses"`   // 0 表示不限次数
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	link := models.ShareLink{
+		ID:         uuid.New().String(),
+		FileID:     fileID,
+		Scope:      req.Scope,
+		MaxUses:    req.MaxUses,
+		CreatorID:  middleware.CurrentUserID(c),
+		CreateTime: time.Now().Unix(),
+	}
+	if link.Permissions() == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "scope must be view, comment or edit")
+		return
+	}
+	if req.ExpiresIn < 0 || req.MaxUses < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid expires_in or max_uses")
+		return
+	}
+	if req.ExpiresIn > 0 {
+		link.ExpireTime = link.CreateTime + req.ExpiresIn
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("生成分享密码哈希失败: %v", err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create share link")
+			return
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		log.Printf("生成分享令牌失败: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create share link")
+		return
+	}
+	link.Token = hex.EncodeToString(token)
+
+	if err := database.DB.Create(&link).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, shareLinkView(&link, time.Now().Unix()))
+}
+
+// ListShareLinks 列出文件的全部分享链接，包括已撤销和已过期的链接（管理接口）
+func ListShareLinks(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	var links []models.ShareLink
+	if err := database.DB.Where("file_id = ?", fileID).
+		Order("create_time DESC").
+		Find(&links).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+
+	now := time.Now().Unix()
+	items := make([]gin.H, 0, len(links))
+	for i := range links {
+		items = append(items, shareLinkView(&links[i], now))
+	}
+	utils.SuccessResponse(c, items)
+}
+
+// RevokeShareLink 撤销分享链接，已换取的令牌随之失效（管理接口）
+func RevokeShareLink(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermManage); !ok {
+		return
+	}
+
+	result := database.DB.Model(&models.ShareLink{}).
+		Where("id = ? AND file_id = ? AND revoke_time = 0", c.Param("share_id"), fileID).
+		Update("revoke_time", time.Now().Unix())
+	if result.Error != nil {
+		handleDatabaseError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		handleDatabaseError(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// OpenShareLink 校验分享链接的密码与使用次数，换取访问文件的 WebOffice 令牌
+func OpenShareLink(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	var link models.ShareLink
+	if err := database.DB.Where("token = ?", c.Param("token")).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "分享链接不存在")
+		} else {
+			handleDatabaseError(c, err)
+		}
+		return
+	}
+	now := time.Now().Unix()
+	if !link.Active(now) {
+		utils.ErrorResponse(c, http.StatusGone, "分享链接已失效")
+		return
+	}
+	if link.PasswordHash != "" &&
+		bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(req.Password)) != nil {
+		utils.ErrorResponse(c, http.StatusForbidden, "分享密码错误")
+		return
+	}
+
+	// 条件递增使用次数，并发打开时不会超出上限
+	result := database.DB.Model(&models.ShareLink{}).
+		Where("id = ? AND (max_uses = 0 OR use_count < max_uses)", link.ID).
+		Update("use_count", gorm.Expr("use_count + 1"))
+	if result.Error != nil {
+		handleDatabaseError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusGone, "分享链接使用次数已达上限")
+		return
+	}
+
+	cfg := appConfig
+	expires := now + int64(cfg.ShareTokenTTL.Seconds())
+	if link.ExpireTime > 0 && link.ExpireTime < expires {
+		expires = link.ExpireTime
+	}
+	utils.SuccessResponse(c, gin.H{
+		"file_id":     link.FileID,
+		"scope":       link.Scope,
+		"permissions": formatPermissions(link.Permissions()),
+		"token":       middleware.IssueShareToken(cfg.URLSignKey, link.ID, expires),
+		"expire_time": expires,
+	})
+}
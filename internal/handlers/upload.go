@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weboffice/internal/config"
+	"weboffice/internal/database"
+	"weboffice/internal/middleware"
+	"weboffice/internal/models"
+	"weboffice/internal/sniff"
+	"weboffice/internal/utils"
+)
+
+// 上传支持的摘要算法，与 PrepareUpload 返回的 digest_types 保持一致
+var digestTypes = []string{"sha1", "md5"}
+
+// PrepareUpload 处理上传准备，开启新的上传会话
+func PrepareUpload(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if fileID == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+	if !requireUploadPermission(c, fileID) {
+		return
+	}
+
+	cfg := appConfig
+	now := time.Now()
+	session := models.UploadSession{
+		ID:         uuid.New().String(),
+		FileID:     fileID,
+		Status:     models.UploadStatusPrepared,
+		CreateTime: now.Unix(),
+		UpdateTime: now.Unix(),
+		ExpireTime: now.Add(cfg.UploadSessionTTL).Unix(),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		log.Printf("创建上传会话失败: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"digest_types": digestTypes,
+	})
+}
+
+// GetUploadAddress 处理获取上传地址
+// 将最近一次准备阶段的会话推进到 addressed，登记声明的文件名、大小与摘要，
+// 并返回指向内置上传端点的一次性地址
+func GetUploadAddress(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if fileID == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+	if !requireUploadPermission(c, fileID) {
+		return
+	}
+
+	var req struct {
+		Name     string            `json:"name"`
+		Size     int64             `json:"size"`
+		Digest   map[string]string `json:"digest"`
+		IsManual bool              `json:"is_manual"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	fileName := filepath.Base(req.Name)
+	if req.Name == "" || req.Size < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid name or size")
+		return
+	}
+	if _, err := fileTypeRules.ValidateFileName(fileName, "", req.Size); err != nil {
+		status := http.StatusUnsupportedMediaType
+		if errors.Is(err, utils.ErrFileTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		utils.ErrorResponse(c, status, err.Error())
+		return
+	}
+	digest, err := normalizeDigest(req.Digest)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var session models.UploadSession
+	if err := database.DB.Where("file_id = ? AND status = ? AND expire_time > ?",
+		fileID, models.UploadStatusPrepared, time.Now().Unix()).
+		Order("create_time DESC").
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusConflict, "上传会话不存在或已过期，请先调用上传准备接口")
+		} else {
+			handleDatabaseError(c, err)
+		}
+		return
+	}
+
+	// 从配置系统获取上传地址
+	cfg := appConfig
+	now := time.Now()
+	result := database.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND status = ?", session.ID, models.UploadStatusPrepared).
+		Updates(map[string]interface{}{
+			"status":        models.UploadStatusAddressed,
+			"name":          fileName,
+			"size":          req.Size,
+			"declared_sha1": digest["sha1"],
+			"declared_md5":  digest["md5"],
+			"update_time":   now.Unix(),
+			"expire_time":   now.Add(cfg.UploadSessionTTL).Unix(),
+		})
+	if result.Error != nil {
+		handleDatabaseError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "上传会话状态已变更")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"url":    fmt.Sprintf("%s/v3/3rd/upload/%s", cfg.BaseURL, session.ID),
+		"method": "PUT",
+		"headers": map[string]string{
+			"Content-Type": "application/octet-stream",
+		},
+	})
+}
+
+// normalizeDigest 校验并规范化客户端声明的摘要，至少需要一种支持的算法
+func normalizeDigest(digest map[string]string) (map[string]string, error) {
+	normalized := make(map[string]string, len(digest))
+	for algo, value := range digest {
+		algo = strings.ToLower(algo)
+		if algo != "sha1" && algo != "md5" {
+			continue
+		}
+		value = strings.ToLower(strings.TrimSpace(value))
+		if _, err := hex.DecodeString(value); err != nil || value == "" {
+			return nil, fmt.Errorf("无效的%s摘要", algo)
+		}
+		normalized[algo] = value
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("缺少sha1或md5摘要")
+	}
+	return normalized, nil
+}
+
+// transitionUploadSession 以条件更新的方式推进会话状态，返回是否推进成功
+func transitionUploadSession(id, from, to string, fields map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{
+		"status":      to,
+		"update_time": time.Now().Unix(),
+	}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	result := database.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	return result.RowsAffected == 1, result.Error
+}
+
+// failUploadSession 将会话标记为失败并删除暂存内容
+func failUploadSession(session *models.UploadSession, from string) {
+	if _, err := transitionUploadSession(session.ID, from, models.UploadStatusFailed, nil); err != nil {
+		log.Printf("更新上传会话状态失败: %v", err)
+	}
+	if err := fileStorage.DeleteStaged(session.ID); err != nil {
+		log.Printf("删除暂存内容失败: %v", err)
+	}
+}
+
+// UploadContent 处理 GetUploadAddress 返回地址上的 PUT 上传
+// 请求体落地到临时文件，计算摘要并与声明的大小、摘要比对，检查内容格式后写入存储暂存区
+func UploadContent(c *gin.Context) {
+	token := c.Param("token")
+
+	var session models.UploadSession
+	if err := database.DB.Where("id = ? AND expire_time > ?", token, time.Now().Unix()).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "上传地址无效或已过期")
+		} else {
+			handleDatabaseError(c, err)
+		}
+		return
+	}
+
+	// 凭证仅能使用一次：由 addressed 原子推进到 uploading
+	ok, err := transitionUploadSession(session.ID, models.UploadStatusAddressed, models.UploadStatusUploading, nil)
+	if err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	if !ok {
+		utils.ErrorResponse(c, http.StatusConflict, "上传地址已被使用")
+		return
+	}
+
+	if c.Request.ContentLength >= 0 && c.Request.ContentLength != session.Size {
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusBadRequest, "上传内容大小与声明不一致")
+		return
+	}
+
+	// 先落地到本地临时文件，校验摘要并检查内容格式后再写入存储暂存区
+	spool, err := os.CreateTemp("", "weboffice-upload-*")
+	if err != nil {
+		log.Printf("创建上传临时文件失败: %v", err)
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "文件存储失败")
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	sha1Hash, md5Hash := sha1.New(), md5.New()
+	// 多读取一个字节用于识别超出声明大小的请求体
+	written, err := io.Copy(io.MultiWriter(spool, sha1Hash, md5Hash), io.LimitReader(c.Request.Body, session.Size+1))
+	if err != nil {
+		log.Printf("接收上传内容失败: %v", err)
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusBadRequest, "接收上传内容失败")
+		return
+	}
+
+	sha1Sum := hex.EncodeToString(sha1Hash.Sum(nil))
+	md5Sum := hex.EncodeToString(md5Hash.Sum(nil))
+	if err := checkUploadContent(&session, written, sha1Sum, md5Sum); err != nil {
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 病毒扫描：感染内容移入隔离区，不生成版本
+	if _, err = spool.Seek(0, io.SeekStart); err != nil {
+		log.Printf("读取上传临时文件失败: %v", err)
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "检查上传内容失败")
+		return
+	}
+	signature, err := scanContent(c.Request.Context(), spool, "上传会话 "+session.ID)
+	if err != nil {
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error()+"，请稍后重试")
+		return
+	}
+	if signature != "" {
+		quarantineUpload(c, &session, spool, map[string]interface{}{
+			"received_size": written,
+			"sha1":          sha1Sum,
+			"md5":           md5Sum,
+			"reason":        "检测到病毒 " + signature,
+		})
+		return
+	}
+
+	// 按魔数与容器结构识别实际格式，拒绝与扩展名不一致的内容
+	ext := strings.TrimPrefix(filepath.Ext(session.Name), ".")
+	detected, err := sniff.CheckExtension(ext, spool, written)
+	if err != nil {
+		failUploadSession(&session, models.UploadStatusUploading)
+		if errors.Is(err, sniff.ErrContentMismatch) {
+			utils.ErrorResponse(c, http.StatusUnsupportedMediaType, err.Error())
+		} else {
+			log.Printf("检查上传内容失败: %v", err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "检查上传内容失败")
+		}
+		return
+	}
+
+	// 检测宏、嵌入对象与外部链接并按策略处理
+	inspection, err := inspectActiveContent(spool, written, detected.Kind)
+	if err != nil {
+		log.Printf("检查上传内容失败: %v", err)
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "检查上传内容失败")
+		return
+	}
+	if len(inspection.details) > 0 {
+		log.Printf("上传会话 %s 检测到主动内容 %v，处理方式 %s", session.ID, inspection.details, inspection.action)
+	}
+
+	fields := activeContentFields(inspection.ActiveContent)
+	fields["received_size"] = written
+	fields["sha1"] = sha1Sum
+	fields["md5"] = md5Sum
+
+	content, storedSize := io.ReadSeeker(spool), written
+	switch inspection.action {
+	case config.ActionReject:
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, inspection.reason+"，不允许上传")
+		return
+	case config.ActionQuarantine:
+		fields["reason"] = inspection.reason
+		quarantineUpload(c, &session, spool, fields)
+		return
+	case config.ActionStrip:
+		stripped, size, err := stripMacros(spool, written)
+		if err != nil {
+			log.Printf("上传会话 %s %v", session.ID, err)
+			failUploadSession(&session, models.UploadStatusUploading)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "处理上传内容失败")
+			return
+		}
+		defer os.Remove(stripped.Name())
+		defer stripped.Close()
+		content, storedSize = stripped, size
+	}
+
+	// 暂存时计算实际保存内容的摘要：SHA-256 用于提交时寻址去重，SHA-1 与 MD5 记入版本清单
+	sha256Hash, storedSha1, storedMd5 := sha256.New(), sha1.New(), md5.New()
+	if _, err = content.Seek(0, io.SeekStart); err == nil {
+		_, err = fileStorage.PutStaged(session.ID, io.TeeReader(content, io.MultiWriter(sha256Hash, storedSha1, storedMd5)))
+	}
+	if err != nil {
+		log.Printf("写入暂存内容失败: %v", err)
+		failUploadSession(&session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "文件存储失败")
+		return
+	}
+
+	fields["stored_size"] = storedSize
+	fields["sha256"] = hex.EncodeToString(sha256Hash.Sum(nil))
+	fields["stored_sha1"] = hex.EncodeToString(storedSha1.Sum(nil))
+	fields["stored_md5"] = hex.EncodeToString(storedMd5.Sum(nil))
+	if _, err := transitionUploadSession(session.ID, models.UploadStatusUploading, models.UploadStatusUploaded,
+		fields); err != nil {
+		failUploadSession(&session, models.UploadStatusUploading)
+		handleDatabaseError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// quarantineUpload 将上传内容写入隔离区，会话标记为已隔离并记录 fields（含 reason），不生成新版本
+func quarantineUpload(c *gin.Context, session *models.UploadSession, content io.ReadSeeker, fields map[string]interface{}) {
+	_, err := content.Seek(0, io.SeekStart)
+	if err == nil {
+		err = fileStorage.Quarantine(session.ID, content)
+	}
+	if err != nil {
+		log.Printf("隔离上传内容失败: %v", err)
+		failUploadSession(session, models.UploadStatusUploading)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "文件存储失败")
+		return
+	}
+
+	reason, _ := fields["reason"].(string)
+	if _, err := transitionUploadSession(session.ID, models.UploadStatusUploading, models.UploadStatusQuarantined,
+		fields); err != nil {
+		log.Printf("更新上传会话状态失败: %v", err)
+	}
+	log.Printf("上传会话 %s 已隔离: %s", session.ID, reason)
+	utils.ErrorResponse(c, http.StatusUnprocessableEntity, reason+"，已隔离待审核")
+}
+
+// checkUploadContent 比对实际接收的内容与会话中声明的大小、摘要
+func checkUploadContent(session *models.UploadSession, size int64, sha1Sum, md5Sum string) error {
+	if size != session.Size {
+		return errors.New("上传内容大小与声明不一致")
+	}
+	if session.DeclaredSha1 != "" && session.DeclaredSha1 != sha1Sum {
+		return errors.New("上传内容sha1摘要不一致")
+	}
+	if session.DeclaredMd5 != "" && session.DeclaredMd5 != md5Sum {
+		return errors.New("上传内容md5摘要不一致")
+	}
+	return nil
+}
+
+// UploadComplete 处理上传完成
+// 根据 WebOffice 提交的完成回执找到已暂存的上传会话，校验后提交为文件的下一个版本
+func UploadComplete(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if fileID == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的文件ID")
+		return
+	}
+	if !requireUploadPermission(c, fileID) {
+		return
+	}
+
+	var req struct {
+		Request struct {
+			Name     string            `json:"name"`
+			Size     int64             `json:"size"`
+			Digest   map[string]string `json:"digest"`
+			IsManual bool              `json:"is_manual"`
+		} `json:"request"`
+		Response struct {
+			StatusCode int               `json:"status_code"`
+			Headers    map[string]string `json:"headers"`
+			Body       string            `json:"body"`
+		} `json:"response"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	currentUserID := middleware.CurrentUserID(c)
+	fileName := filepath.Base(req.Request.Name)
+
+	var session models.UploadSession
+	if err := database.DB.Where("file_id = ? AND status = ? AND name = ? AND expire_time > ?",
+		fileID, models.UploadStatusUploaded, fileName, time.Now().Unix()).
+		Order("update_time DESC").
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusConflict, "未找到已上传的文件内容")
+		} else {
+			handleDatabaseError(c, err)
+		}
+		return
+	}
+
+	// WebOffice 上传到存储端点失败时放弃本次会话
+	if req.Response.StatusCode < 200 || req.Response.StatusCode >= 300 {
+		failUploadSession(&session, models.UploadStatusUploaded)
+		utils.ErrorResponse(c, http.StatusBadRequest,
+			fmt.Sprintf("上传未成功完成（状态码%d）", req.Response.StatusCode))
+		return
+	}
+
+	digest, err := normalizeDigest(req.Request.Digest)
+	if err == nil {
+		session.DeclaredSha1, session.DeclaredMd5 = digest["sha1"], digest["md5"]
+		session.Size = req.Request.Size
+		err = checkUploadContent(&session, session.ReceivedSize, session.Sha1, session.Md5)
+	}
+	if err != nil {
+		failUploadSession(&session, models.UploadStatusUploaded)
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 由 uploaded 原子推进到 completed，防止同一会话被重复提交
+	ok, err := transitionUploadSession(session.ID, models.UploadStatusUploaded, models.UploadStatusCompleted, nil)
+	if err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	if !ok {
+		utils.ErrorResponse(c, http.StatusConflict, "上传会话已被提交")
+		return
+	}
+
+	// 主动内容被移除时实际保存的内容小于接收的内容
+	storedSize := session.StoredSize
+	if storedSize == 0 {
+		storedSize = session.ReceivedSize
+	}
+	currentVersion, err := commitFileVersion(fileID, currentUserID,
+		models.FileVersion{
+			Name:   session.Name,
+			Size:   int(storedSize),
+			Sha256: session.Sha256,
+			VersionManifest: models.VersionManifest{
+				Sha1:         session.StoredSha1,
+				Md5:          session.StoredMd5,
+				ContentType:  utils.ContentTypeByName(session.Name),
+				OriginalName: session.Name,
+			},
+			ActiveContent: session.ActiveContent,
+		},
+		func(_ int, blobExists bool) error {
+			if blobExists {
+				if err := fileStorage.DeleteStaged(session.ID); err != nil {
+					log.Printf("删除暂存内容失败: %v", err)
+				}
+				return nil
+			}
+			return fileStorage.CommitStagedObject(session.ID, session.Sha256)
+		})
+	if err != nil {
+		log.Printf("上传处理失败: %v", err)
+		failUploadSession(&session, models.UploadStatusCompleted)
+		if errors.Is(err, errPermissionDenied) {
+			utils.ErrorResponse(c, http.StatusForbidden, "Permission denied")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError,
+			fmt.Sprintf("上传处理失败: %v", err))
+		return
+	}
+
+	var file models.File
+	if err := database.DB.Where("id = ?", fileID).First(&file).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	log.Printf("文件 %s 已提交版本 %d", fileID, currentVersion)
+	utils.SuccessResponse(c, file)
+}
+
+// CleanupUploadSessions 删除已过期的上传会话及其暂存内容
+func CleanupUploadSessions() error {
+	var sessions []models.UploadSession
+	// 已隔离的会话保留用于审核
+	if err := database.DB.Where("expire_time <= ? AND status <> ?", time.Now().Unix(), models.UploadStatusQuarantined).
+		Find(&sessions).Error; err != nil {
+		return fmt.Errorf("查询过期上传会话失败: %w", err)
+	}
+	for _, session := range sessions {
+		if session.Status != models.UploadStatusCompleted {
+			if err := fileStorage.DeleteStaged(session.ID); err != nil {
+				log.Printf("删除暂存内容失败: %v", err)
+				continue
+			}
+		}
+		if err := database.DB.Delete(&models.UploadSession{}, "id = ?", session.ID).Error; err != nil {
+			return fmt.Errorf("删除上传会话失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// StartUploadSessionJanitor 启动后台任务，定期清理过期的上传会话
+func StartUploadSessionJanitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := CleanupUploadSessions(); err != nil {
+				log.Printf("清理上传会话失败: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,476 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"weboffice/internal/config"
+	"weboffice/internal/middleware"
+	"weboffice/internal/models"
+)
+
+// macroDocument 返回包含 VBA 工程的最小 docm 文档
+func macroDocument(t *testing.T) []byte {
+	t.Helper()
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Default Extension="bin" ContentType="application/vnd.ms-office.vbaProject"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.ms-word.document.macroEnabled.main+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+			`</Relationships>`},
+		{"word/document.xml", `<document/>`},
+		{"word/_rels/document.xml.rels", `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.microsoft.com/office/2006/relationships/vbaProject" Target="vbaProject.bin"/>` +
+			`</Relationships>`},
+		{"word/vbaProject.bin", strings.Repeat("\xd0\xcf\x11\xe0 vba ", 64)},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, p := range parts {
+		w, err := zw.Create(p.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(p.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// allowMacroDocuments 放行 docm 扩展名，由 active_content.macros 决定如何处理宏
+func allowMacroDocuments(action string) func(cfg *config.AppConfig) {
+	return func(cfg *config.AppConfig) {
+		cfg.AllowedFileTypes["document"].BlockedExtensions = nil
+		cfg.ActiveContent.Macros = action
+	}
+}
+
+// session 按上传端点路径中的凭证查询上传会话
+func (e *testEnv) session(target string) models.UploadSession {
+	e.t.Helper()
+	var session models.UploadSession
+	if err := e.db.Where("id = ?", path.Base(target)).First(&session).Error; err != nil {
+		e.t.Fatalf("查询上传会话: %v", err)
+	}
+	return session
+}
+
+// expireSession 将上传会话的过期时间改为已过去
+func (e *testEnv) expireSession(target string) {
+	e.t.Helper()
+	if err := e.db.Model(&models.UploadSession{}).Where("id = ?", path.Base(target)).
+		Update("expire_time", time.Now().Add(-time.Minute).Unix()).Error; err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// staged 判断上传会话是否仍有暂存内容：内存存储未提供查询接口，
+// 借助 CommitStagedObject 取出暂存内容后再放回
+func (e *testEnv) staged(target string) bool {
+	e.t.Helper()
+	token := path.Base(target)
+	probe := "probe-" + token
+	if err := e.store.CommitStagedObject(token, probe); err != nil {
+		return false
+	}
+	rc, err := e.store.GetObject(probe)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := e.store.PutStaged(token, rc); err != nil {
+		e.t.Fatal(err)
+	}
+	if err := e.store.DeleteObject(probe); err != nil {
+		e.t.Fatal(err)
+	}
+	return true
+}
+
+func TestUploadSessionLifecycle(t *testing.T) {
+	env := newTestEnv(t, nil)
+	content := []byte("hello weboffice")
+	digest := sha1Digest(content)
+	base := "/v3/3rd/files/f1/upload"
+
+	// 未调用上传准备时不能获取上传地址
+	env.expect(env.do(http.MethodPost, base+"/address", "tok-alice", map[string]interface{}{
+		"name": "notes.txt", "size": len(content), "digest": digest,
+	}), http.StatusConflict)
+
+	target := env.address("tok-alice", "f1", "dir/notes.txt", len(content), digest)
+	s := env.session(target)
+	if s.Status != models.UploadStatusAddressed || s.Name != "notes.txt" || s.Size != int64(len(content)) || s.DeclaredSha1 != digest["sha1"] {
+		t.Fatalf("获取地址后的会话 %+v", s)
+	}
+
+	// 内容尚未上传时不能提交
+	env.expect(env.complete("tok-alice", "f1", "notes.txt", content, http.StatusOK), http.StatusConflict)
+
+	env.expect(env.do(http.MethodPut, target, "", content), http.StatusOK)
+	s = env.session(target)
+	md5Sum := md5.Sum(content)
+	if s.Status != models.UploadStatusUploaded || s.ReceivedSize != int64(len(content)) || s.StoredSize != s.ReceivedSize ||
+		s.Sha1 != digest["sha1"] || s.StoredSha1 != s.Sha1 || s.Md5 != hex.EncodeToString(md5Sum[:]) || s.Sha256 == "" {
+		t.Fatalf("上传后的会话 %+v", s)
+	}
+
+	// 上传地址只能使用一次
+	w := env.do(http.MethodPut, target, "", content)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "上传地址已被使用") {
+		t.Fatalf("重复上传返回 %d: %s", w.Code, w.Body)
+	}
+
+	file := env.expect(env.complete("tok-alice", "f1", "notes.txt", content, http.StatusOK), http.StatusOK)
+	if file["Version"] != float64(1) || file["Name"] != "notes.txt" || file["size"] != float64(len(content)) {
+		t.Fatalf("提交后的文件 %v", file)
+	}
+	if s = env.session(target); s.Status != models.UploadStatusCompleted {
+		t.Errorf("提交后的会话状态 %s", s.Status)
+	}
+	var version models.FileVersion
+	env.db.Where("id = ? AND version = ?", "f1", 1).First(&version)
+	if version.Sha1 != s.Sha1 || version.Md5 != s.Md5 || version.Sha256 != s.Sha256 || version.ModifierID != "alice" {
+		t.Errorf("版本记录 %+v", version)
+	}
+	if env.staged(target) {
+		t.Error("提交后暂存内容未清除")
+	}
+
+	// 同一会话不能重复提交
+	env.expect(env.complete("tok-alice", "f1", "notes.txt", content, http.StatusOK), http.StatusConflict)
+
+	// 内容相同的新版本复用已有的 Blob
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", content), http.StatusOK)
+	var blob models.Blob
+	env.db.Where("sha256 = ?", s.Sha256).First(&blob)
+	if blob.RefCount != 2 {
+		t.Errorf("Blob 引用计数 %d，期望 2", blob.RefCount)
+	}
+}
+
+func TestUploadContentRejected(t *testing.T) {
+	content := []byte("plain text content")
+	tests := []struct {
+		name     string
+		fileName string
+		size     int
+		digest   map[string]string
+		req      func(target string) *http.Request
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name:     "declared length differs",
+			fileName: "notes.txt",
+			size:     len(content) + 1,
+			digest:   sha1Digest(content),
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "上传内容大小与声明不一致",
+		},
+		{
+			name:     "chunked body longer than declared",
+			fileName: "notes.txt",
+			size:     len(content) - 1,
+			digest:   sha1Digest(content[:len(content)-1]),
+			req: func(target string) *http.Request {
+				req := httptest.NewRequest(http.MethodPut, target, bytes.NewReader(content))
+				req.ContentLength = -1
+				return req
+			},
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "上传内容大小与声明不一致",
+		},
+		{
+			name:     "sha1 mismatch",
+			fileName: "notes.txt",
+			size:     len(content),
+			digest:   sha1Digest([]byte("other content")),
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "sha1摘要不一致",
+		},
+		{
+			name:     "md5 mismatch",
+			fileName: "notes.txt",
+			size:     len(content),
+			digest:   map[string]string{"md5": strings.Repeat("0", 32)},
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "md5摘要不一致",
+		},
+		{
+			name:     "content does not match extension",
+			fileName: "report.docx",
+			size:     len(content),
+			digest:   sha1Digest(content),
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, nil)
+			target := env.address("tok-alice", "f1", tc.fileName, tc.size, tc.digest)
+			req := httptest.NewRequest(http.MethodPut, target, bytes.NewReader(content))
+			if tc.req != nil {
+				req = tc.req(target)
+			}
+			w := httptest.NewRecorder()
+			env.router.ServeHTTP(w, req)
+			if w.Code != tc.wantCode || !strings.Contains(w.Body.String(), tc.wantMsg) {
+				t.Fatalf("返回 %d %s，期望 %d 且包含 %q", w.Code, w.Body, tc.wantCode, tc.wantMsg)
+			}
+			if s := env.session(target); s.Status != models.UploadStatusFailed {
+				t.Errorf("会话状态 %s，期望 failed", s.Status)
+			}
+			if env.staged(target) {
+				t.Error("被拒绝的内容不应暂存")
+			}
+			// 失败的会话不能再上传或提交
+			env.expect(env.do(http.MethodPut, target, "", content), http.StatusConflict)
+			env.expect(env.complete("tok-alice", "f1", tc.fileName, content, http.StatusOK), http.StatusConflict)
+		})
+	}
+}
+
+func TestUploadAddressRejectsBlockedExtension(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.expect(env.do(http.MethodGet, "/v3/3rd/files/f1/upload/prepare", "tok-alice", nil), http.StatusOK)
+	doc := macroDocument(t)
+	env.expect(env.do(http.MethodPost, "/v3/3rd/files/f1/upload/address", "tok-alice", map[string]interface{}{
+		"name": "macro.docm", "size": len(doc), "digest": sha1Digest(doc),
+	}), http.StatusUnsupportedMediaType)
+}
+
+func TestUploadActiveContent(t *testing.T) {
+	doc := macroDocument(t)
+	tests := []struct {
+		action     string
+		wantCode   int
+		wantStatus string
+	}{
+		{config.ActionAllow, http.StatusOK, models.UploadStatusUploaded},
+		{config.ActionReject, http.StatusUnsupportedMediaType, models.UploadStatusFailed},
+		{config.ActionQuarantine, http.StatusUnprocessableEntity, models.UploadStatusQuarantined},
+	}
+	for _, tc := range tests {
+		t.Run(tc.action, func(t *testing.T) {
+			env := newTestEnv(t, allowMacroDocuments(tc.action))
+			target := env.address("tok-alice", "f1", "macro.docm", len(doc), sha1Digest(doc))
+			env.expect(env.do(http.MethodPut, target, "", doc), tc.wantCode)
+
+			s := env.session(target)
+			if s.Status != tc.wantStatus {
+				t.Fatalf("会话状态 %s，期望 %s", s.Status, tc.wantStatus)
+			}
+			if tc.action != config.ActionReject && !s.HasMacros {
+				t.Error("会话未记录检测到的宏")
+			}
+			if tc.action == config.ActionQuarantine && s.Reason == "" {
+				t.Error("隔离的会话未记录原因")
+			}
+			if env.staged(target) != (tc.wantStatus == models.UploadStatusUploaded) {
+				t.Errorf("状态 %s 的暂存内容存在状态不正确", s.Status)
+			}
+
+			w := env.complete("tok-alice", "f1", "macro.docm", doc, http.StatusOK)
+			if tc.wantStatus != models.UploadStatusUploaded {
+				env.expect(w, http.StatusConflict)
+				return
+			}
+			env.expect(w, http.StatusOK)
+			var version models.FileVersion
+			env.db.Where("id = ? AND version = ?", "f1", 1).First(&version)
+			if !version.HasMacros || version.ActiveContentAction != config.ActionAllow || version.Size != len(doc) {
+				t.Errorf("版本记录 %+v", version)
+			}
+		})
+	}
+}
+
+func TestUploadStripsMacros(t *testing.T) {
+	env := newTestEnv(t, allowMacroDocuments(config.ActionStrip))
+	doc := macroDocument(t)
+	target := env.address("tok-alice", "f1", "macro.docm", len(doc), sha1Digest(doc))
+	env.expect(env.do(http.MethodPut, target, "", doc), http.StatusOK)
+
+	s := env.session(target)
+	if !s.HasMacros || s.ActiveContentAction != config.ActionStrip || s.ReceivedSize != int64(len(doc)) ||
+		s.StoredSize <= 0 || s.StoredSize >= s.ReceivedSize || s.StoredSha1 == s.Sha1 {
+		t.Fatalf("移除宏后的会话 %+v", s)
+	}
+
+	// 提交回执中的摘要是原始内容的摘要
+	env.expect(env.complete("tok-alice", "f1", "macro.docm", doc, http.StatusOK), http.StatusOK)
+
+	// 版本记录的大小与摘要对应实际保存的内容
+	stored := []byte(env.latestContent("f1"))
+	sha1Sum, md5Sum, sha256Sum := sha1.Sum(stored), md5.Sum(stored), sha256.Sum256(stored)
+	version := env.version("f1", 1)
+	if version.Size != len(stored) || int64(len(stored)) != s.StoredSize ||
+		version.Sha1 != hex.EncodeToString(sha1Sum[:]) || version.Md5 != hex.EncodeToString(md5Sum[:]) ||
+		version.Sha256 != hex.EncodeToString(sha256Sum[:]) {
+		t.Errorf("版本记录 %+v 与保存的内容（%d 字节）不一致", version, len(stored))
+	}
+	if !version.HasMacros || version.ActiveContentAction != config.ActionStrip {
+		t.Errorf("版本未记录移除的宏: %+v", version.ActiveContent)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(stored), int64(len(stored)))
+	if err != nil {
+		t.Fatalf("保存的内容不是有效的 zip 包: %v", err)
+	}
+	for _, f := range zr.File {
+		if strings.Contains(f.Name, "vbaProject") {
+			t.Errorf("保存的内容仍包含 %s", f.Name)
+		}
+	}
+}
+
+func TestUploadCompleteWithFailedResponse(t *testing.T) {
+	env := newTestEnv(t, nil)
+	content := []byte("content")
+	target := env.address("tok-alice", "f1", "notes.txt", len(content), sha1Digest(content))
+	env.expect(env.do(http.MethodPut, target, "", content), http.StatusOK)
+
+	w := env.complete("tok-alice", "f1", "notes.txt", content, http.StatusBadGateway)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "状态码502") {
+		t.Fatalf("返回 %d: %s", w.Code, w.Body)
+	}
+	if s := env.session(target); s.Status != models.UploadStatusFailed {
+		t.Errorf("会话状态 %s，期望 failed", s.Status)
+	}
+	if env.staged(target) {
+		t.Error("放弃的会话未清除暂存内容")
+	}
+	env.expect(env.complete("tok-alice", "f1", "notes.txt", content, http.StatusOK), http.StatusConflict)
+	var count int64
+	env.db.Model(&models.File{}).Where("id = ?", "f1").Count(&count)
+	if count != 0 {
+		t.Error("上传失败时不应创建文件")
+	}
+}
+
+func TestUploadSessionExpiry(t *testing.T) {
+	env := newTestEnv(t, allowMacroDocuments(config.ActionQuarantine))
+	content := []byte("content")
+	digest := sha1Digest(content)
+
+	// 准备阶段的会话过期后不能获取上传地址
+	env.expect(env.do(http.MethodGet, "/v3/3rd/files/f1/upload/prepare", "tok-alice", nil), http.StatusOK)
+	env.db.Model(&models.UploadSession{}).Where("file_id = ?", "f1").
+		Update("expire_time", time.Now().Add(-time.Minute).Unix())
+	env.expect(env.do(http.MethodPost, "/v3/3rd/files/f1/upload/address", "tok-alice", map[string]interface{}{
+		"name": "notes.txt", "size": len(content), "digest": digest,
+	}), http.StatusConflict)
+
+	// 上传地址过期后不能上传
+	addressed := env.address("tok-alice", "f2", "notes.txt", len(content), digest)
+	env.expireSession(addressed)
+	env.expect(env.do(http.MethodPut, addressed, "", content), http.StatusNotFound)
+
+	// 已上传的内容过期后不能提交
+	uploaded := env.address("tok-alice", "f3", "notes.txt", len(content), digest)
+	env.expect(env.do(http.MethodPut, uploaded, "", content), http.StatusOK)
+	env.expireSession(uploaded)
+	env.expect(env.complete("tok-alice", "f3", "notes.txt", content, http.StatusOK), http.StatusConflict)
+
+	// 已隔离的会话过期后保留用于审核
+	doc := macroDocument(t)
+	quarantined := env.address("tok-alice", "f4", "macro.docm", len(doc), sha1Digest(doc))
+	env.expect(env.do(http.MethodPut, quarantined, "", doc), http.StatusUnprocessableEntity)
+	env.expireSession(quarantined)
+
+	active := env.address("tok-alice", "f5", "notes.txt", len(content), digest)
+
+	if err := CleanupUploadSessions(); err != nil {
+		t.Fatalf("CleanupUploadSessions: %v", err)
+	}
+	var remaining []string
+	env.db.Model(&models.UploadSession{}).Order("file_id").Pluck("file_id", &remaining)
+	if strings.Join(remaining, ",") != "f4,f5" {
+		t.Errorf("清理后剩余会话 %v，期望 f4,f5", remaining)
+	}
+	if env.staged(uploaded) {
+		t.Error("过期会话的暂存内容未删除")
+	}
+	if s := env.session(active); s.Status != models.UploadStatusAddressed {
+		t.Errorf("未过期的会话状态 %s", s.Status)
+	}
+}
+
+func TestUploadPermission(t *testing.T) {
+	env := newTestEnv(t, nil)
+	now := time.Now().Unix()
+	shareToken := func(linkID, fileID, scope string) string {
+		t.Helper()
+		link := models.ShareLink{
+			ID: linkID, FileID: fileID, Token: linkID, Scope: scope, CreatorID: "alice", CreateTime: now,
+		}
+		if err := env.db.Create(&link).Error; err != nil {
+			t.Fatal(err)
+		}
+		return middleware.IssueShareToken(env.cfg.URLSignKey, link.ID, now+3600)
+	}
+	prepare := func(token, fileID string) int {
+		return env.do(http.MethodGet, "/v3/3rd/files/"+fileID+"/upload/prepare", token, nil).Code
+	}
+
+	// 只有已登记的用户可以创建新文件，分享访客不能创建文件
+	if code := prepare("tok-ghost", "f1"); code != http.StatusForbidden {
+		t.Errorf("未登记的用户创建文件返回 %d，期望 403", code)
+	}
+	if code := prepare(shareToken("new-file", "f1", models.ShareScopeEdit), "f1"); code != http.StatusNotFound {
+		t.Errorf("分享访客创建文件返回 %d，期望 404", code)
+	}
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("v1")), http.StatusOK)
+	env.grant("f1", "bob", models.PermRead|models.PermDownload)
+
+	// 已存在的文件需要编辑权限
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{"owner", "tok-alice", http.StatusOK},
+		{"read-only grant", "tok-bob", http.StatusForbidden},
+		{"unregistered user", "tok-ghost", http.StatusForbidden},
+		{"edit share link", shareToken("edit", "f1", models.ShareScopeEdit), http.StatusOK},
+		{"view share link", shareToken("view", "f1", models.ShareScopeView), http.StatusForbidden},
+		{"share link of another file", shareToken("other", "f2", models.ShareScopeEdit), http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := prepare(tc.token, "f1"); code != tc.wantCode {
+				t.Errorf("返回 %d，期望 %d", code, tc.wantCode)
+			}
+		})
+	}
+
+	// 获得编辑权限后可以提交新版本
+	env.db.Model(&models.FileACL{}).Where("file_id = ? AND subject_id = ?", "f1", "bob").
+		Update("permissions", models.PermRead|models.PermUpdate)
+	file := env.expect(env.upload("tok-bob", "f1", "notes.txt", []byte("v2")), http.StatusOK)
+	if file["Version"] != float64(2) || file["modifier_id"] != "bob" {
+		t.Errorf("提交后的文件 %v", file)
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weboffice/internal/database"
+	"weboffice/internal/docdiff"
+	"weboffice/internal/middleware"
+	"weboffice/internal/models"
+	"weboffice/internal/storage"
+	"weboffice/internal/utils"
+)
+
+// RestoreVersion 以历史版本的内容与文件名创建新的当前版本，记录为执行恢复的用户；
+// 与上传提交一样在主文件行级锁下分配版本号
+func RestoreVersion(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermUpdate|models.PermHistory); !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid version number")
+		return
+	}
+
+	var source models.FileVersion
+	if err := database.DB.Where("id = ? AND version = ?", fileID, version).
+		First(&source).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Version not found")
+		} else {
+			handleDatabaseError(c, err)
+		}
+		return
+	}
+
+	// 沿用原版本的内容清单，存储键由内容摘要确定
+	meta := models.FileVersion{
+		Name:            source.Name,
+		Size:            source.Size,
+		Sha256:          source.Sha256,
+		VersionManifest: source.VersionManifest,
+		ActiveContent:   source.ActiveContent,
+	}
+	meta.StorageKey = ""
+	// 内容已在 Blob 中，只需登记引用；查询后 Blob 可能已被保留策略与垃圾回收清理，此时放弃恢复
+	saveContent := func(_ int, blobExists bool) error {
+		if !blobExists {
+			return errContentMissing
+		}
+		return nil
+	}
+
+	// 旧版本按版本号存储，先转存为按摘要寻址的内容
+	if source.Sha256 == "" {
+		spool, sum, size, err := spoolVersionContent(&source)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				utils.ErrorResponse(c, http.StatusNotFound, "文件内容不存在")
+			} else {
+				log.Printf("读取版本内容失败: %v", err)
+				utils.ErrorResponse(c, http.StatusInternalServerError, "文件访问失败")
+			}
+			return
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		meta.Sha256, meta.Size = sum, int(size)
+		saveContent = func(_ int, blobExists bool) error {
+			if blobExists {
+				return nil
+			}
+			if _, err := spool.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			_, err := fileStorage.PutObject(sum, spool)
+			return err
+		}
+	}
+
+	currentVersion, err := commitFileVersion(fileID, middleware.CurrentUserID(c), meta, saveContent)
+	if err != nil {
+		log.Printf("恢复版本失败: %v", err)
+		if errors.Is(err, errPermissionDenied) {
+			utils.ErrorResponse(c, http.StatusForbidden, "Permission denied")
+			return
+		}
+		if errors.Is(err, errContentMissing) {
+			utils.ErrorResponse(c, http.StatusConflict, "版本内容已被清理，无法恢复")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError,
+			fmt.Sprintf("恢复版本失败: %v", err))
+		return
+	}
+
+	var file models.File
+	if err := database.DB.Where("id = ?", fileID).First(&file).Error; err != nil {
+		handleDatabaseError(c, err)
+		return
+	}
+	log.Printf("文件 %s 已由版本 %d 恢复为版本 %d", fileID, version, currentVersion)
+	utils.SuccessResponse(c, file)
+}
+
+// spoolVersionContent 将版本内容读入临时文件并计算 SHA-256 与实际大小，调用方负责关闭并删除临时文件
+func spoolVersionContent(version *models.FileVersion) (*os.File, string, int64, error) {
+	reader, err := openVersionContent(version)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer reader.Close()
+
+	spool, err := os.CreateTemp("", "weboffice-restore-*")
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), reader)
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, "", 0, fmt.Errorf("读取版本内容失败: %w", err)
+	}
+	return spool, hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// 版本标注长度上限（字符数），与 file_versions 列宽一致
+const (
+	maxVersionLabelLength   = 100
+	maxVersionCommentLength = 1000
+)
+
+// LabelVersion 设置版本的里程碑标签与说明，均为空时清除标注
+func LabelVersion(c *gin.Context) {
+	var req struct {
+		Label   string `json:"label"`
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Label, req.Comment = strings.TrimSpace(req.Label), strings.TrimSpace(req.Comment)
+	if utf8.RuneCountInString(req.Label) > maxVersionLabelLength {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("标签不能超过 %d 个字符", maxVersionLabelLength))
+		return
+	}
+	if utf8.RuneCountInString(req.Comment) > maxVersionCommentLength {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("说明不能超过 %d 个字符", maxVersionCommentLength))
+		return
+	}
+
+	updateVersion(c, map[string]interface{}{"label": req.Label, "comment": req.Comment})
+}
+
+// PinVersion 固定版本，固定的版本不会被保留策略清理
+func PinVersion(c *gin.Context) {
+	updateVersion(c, map[string]interface{}{"pinned": true})
+}
+
+// UnpinVersion 取消固定版本
+func UnpinVersion(c *gin.Context) {
+	updateVersion(c, map[string]interface{}{"pinned": false})
+}
+
+// updateVersion 校验权限后更新版本的标注字段，并返回更新后的版本
+func updateVersion(c *gin.Context, updates map[string]interface{}) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	if _, ok := requireFilePermission(c, fileID, models.PermUpdate|models.PermHistory); !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid version number")
+		return
+	}
+
+	var versionData models.FileVersion
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 与提交新版本、保留策略清理使用同一把锁，避免固定与清理交错
+		var file models.File
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", fileID).
+			First(&file).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND version = ?", fileID, version).
+			First(&versionData).Error; err != nil {
+			return err
+		}
+		// 更新后的字段值同时写回 versionData
+		return tx.Model(&versionData).Updates(updates).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Version not found")
+		} else {
+			handleDatabaseError(c, err)
+		}
+		return
+	}
+	utils.SuccessResponse(c, versionData)
+}
+
+// DiffVersions 比较文件的两个版本（from 必填，to 默认为当前版本）的文本内容，
+// 返回段落、单元格、幻灯片或行级别的差异
+func DiffVersions(c *gin.Context) {
+	fileID := utils.SanitizeID(c.Param("file_id"))
+	file, ok := requireFilePermission(c, fileID, models.PermRead|models.PermHistory)
+	if !ok {
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from version")
+		return
+	}
+	to := file.Version
+	if raw := c.Query("to"); raw != "" && raw != "latest" {
+		if to, err = strconv.Atoi(raw); err != nil || to <= 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to version")
+			return
+		}
+	}
+
+	var docs [2]*docdiff.Document
+	for i, version := range []int{from, to} {
+		doc, status, err := extractVersionText(fileID, version)
+		if err != nil {
+			utils.ErrorResponse(c, status, err.Error())
+			return
+		}
+		docs[i] = doc
+	}
+
+	result, err := docdiff.Compare(docs[0], docs[1])
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"file_id": fileID,
+		"from":    from,
+		"to":      to,
+		"format":  result.Format,
+		"changes": result.Changes,
+		"stats":   result.Stats,
+	})
+}
+
+// extractVersionText 读取版本内容并按其文件名对应的格式提取文本，失败时返回对应的 HTTP 状态码
+func extractVersionText(fileID string, version int) (*docdiff.Document, int, error) {
+	var versionData models.FileVersion
+	if err := database.DB.Where("id = ? AND version = ?", fileID, version).
+		First(&versionData).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, http.StatusNotFound, fmt.Errorf("版本 %d 不存在", version)
+		}
+		log.Printf("Database error: %v", err)
+		return nil, http.StatusInternalServerError, errors.New("Database error")
+	}
+	format := docdiff.FormatOf(versionData.Name)
+	if format == "" {
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("版本 %d 的文件格式不支持比较", version)
+	}
+
+	spool, _, size, err := spoolVersionContent(&versionData)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, http.StatusNotFound, fmt.Errorf("版本 %d 的文件内容不存在", version)
+		}
+		log.Printf("读取版本内容失败: %v", err)
+		return nil, http.StatusInternalServerError, errors.New("文件访问失败")
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	doc, err := docdiff.Extract(spool, size, format)
+	if err != nil {
+		if errors.Is(err, docdiff.ErrInvalidDocument) {
+			return nil, http.StatusUnprocessableEntity, fmt.Errorf("版本 %d: %v", version, err)
+		}
+		log.Printf("提取版本文本失败: %v", err)
+		return nil, http.StatusInternalServerError, errors.New("文本提取失败")
+	}
+	return doc, http.StatusOK, nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"weboffice/internal/models"
+)
+
+// latestContent 经签名下载链接读取文件最新版本的内容
+func (e *testEnv) latestContent(fileID string) string {
+	e.t.Helper()
+	w := e.do(http.MethodGet, e.signedContentURL(fileID, "latest", "alice", time.Now().Add(time.Minute)), "", nil)
+	if w.Code != http.StatusOK {
+		e.t.Fatalf("下载文件 %s 返回 %d: %s", fileID, w.Code, w.Body)
+	}
+	return w.Body.String()
+}
+
+func (e *testEnv) version(fileID string, version int) models.FileVersion {
+	e.t.Helper()
+	var v models.FileVersion
+	if err := e.db.Where("id = ? AND version = ?", fileID, version).First(&v).Error; err != nil {
+		e.t.Fatalf("查询版本 %s/%d: %v", fileID, version, err)
+	}
+	return v
+}
+
+func TestRestoreVersion(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("第一版")), http.StatusOK)
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("第二版")), http.StatusOK)
+	env.grant("f1", "bob", models.PermRead|models.PermUpdate|models.PermDownload)
+
+	tests := []struct {
+		name     string
+		token    string
+		version  string
+		wantCode int
+	}{
+		{"without history permission", "tok-bob", "1", http.StatusForbidden},
+		{"missing version", "tok-alice", "9", http.StatusNotFound},
+		{"invalid version", "tok-alice", "0", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			env.expect(env.do(http.MethodPost, "/v3/3rd/files/f1/versions/"+tc.version+"/restore", tc.token, nil), tc.wantCode)
+		})
+	}
+
+	file := env.expect(env.do(http.MethodPost, "/v3/3rd/files/f1/versions/1/restore", "tok-alice", nil), http.StatusOK)
+	if file["Version"] != float64(3) {
+		t.Fatalf("恢复后的文件 %v", file)
+	}
+	if got := env.latestContent("f1"); got != "第一版" {
+		t.Errorf("恢复后的内容 %q", got)
+	}
+	restored, source := env.version("f1", 3), env.version("f1", 1)
+	if restored.Sha256 != source.Sha256 || restored.Sha1 != source.Sha1 || restored.ModifierID != "alice" {
+		t.Errorf("恢复的版本 %+v", restored)
+	}
+	var blob models.Blob
+	env.db.Where("sha256 = ?", source.Sha256).First(&blob)
+	if blob.RefCount != 2 {
+		t.Errorf("Blob 引用计数 %d，期望 2", blob.RefCount)
+	}
+}
+
+func TestRestoreVersionContentCollected(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("第一版")), http.StatusOK)
+	env.expect(env.upload("tok-alice", "f1", "notes.txt", []byte("第二版")), http.StatusOK)
+
+	// 模拟查询版本后 Blob 被保留策略与垃圾回收清理
+	env.db.Where("sha256 = ?", env.version("f1", 1).Sha256).Delete(&models.Blob{})
+
+	w := env.do(http.MethodPost, "/v3/3rd/files/f1/versions/1/restore", "tok-alice", nil)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "版本内容已被清理") {
+		t.Fatalf("返回 %d: %s", w.Code, w.Body)
+	}
+	var file models.File
+	env.db.Where("id = ?", "f1").First(&file)
+	var count int64
+	env.db.Model(&models.FileVersion{}).Where("id = ?", "f1").Count(&count)
+	if file.Version != 2 || count != 2 {
+		t.Errorf("恢复失败后文件版本为 %d，版本记录 %d 条，期望保持不变", file.Version, count)
+	}
+	if got := env.latestContent("f1"); got != "第二版" {
+		t.Errorf("恢复失败后的内容 %q", got)
+	}
+}
+
+func TestRestoreLegacyVersion(t *testing.T) {
+	env := newTestEnv(t, nil)
+	now := time.Now().Unix()
+	// 0010 之前按版本号存储、未记录摘要的版本，第 2 个版本的内容已丢失
+	if err := env.db.Create(&models.File{
+		ID: "f1", Name: "notes.txt", Version: 2, Size: 6, CreateTime: now, ModifyTime: now,
+		CreatorID: "alice", ModifierID: "alice",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for version := 1; version <= 2; version++ {
+		if err := env.db.Create(&models.FileVersion{
+			ID: "f1", Version: version, Name: "notes.txt", Size: 6, ModifierID: "alice",
+			VersionManifest: models.VersionManifest{StorageKey: "f1/v" + strconv.Itoa(version)},
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := env.store.SaveFile("f1", 1, "notes.txt", strings.NewReader("旧版")); err != nil {
+		t.Fatal(err)
+	}
+
+	env.expect(env.do(http.MethodPost, "/v3/3rd/files/f1/versions/2/restore", "tok-alice", nil), http.StatusNotFound)
+
+	env.expect(env.do(http.MethodPost, "/v3/3rd/files/f1/versions/1/restore", "tok-alice", nil), http.StatusOK)
+	restored := env.version("f1", 3)
+	if restored.Sha256 == "" || restored.Size != len("旧版") {
+		t.Errorf("恢复的版本 %+v", restored)
+	}
+	if got := env.latestContent("f1"); got != "旧版" {
+		t.Errorf("恢复后的内容 %q", got)
+	}
+}
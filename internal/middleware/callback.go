@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"weboffice/internal/config"
+	"weboffice/internal/utils"
+)
+
+// WebOffice 回调请求头
+const (
+	HeaderAppID     = "X-App-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+	HeaderToken     = "X-WebOffice-Token"
+)
+
+// 上下文键
+const (
+	ContextAppID = "weboffice.app_id"
+	ContextToken = "weboffice.token"
+)
+
+// 请求体在内存中缓存的上限，超出后转存到临时文件
+const maxMemoryBodySize = 1 << 20
+
+// CallbackAuth 校验 WebOffice 回调签名
+//
+// 签名为 hex(HMAC-SHA256(应用密钥, METHOD\nREQUEST_URI\nTIMESTAMP\nhex(SHA256(body))\nTOKEN))，
+// 时间戳与服务器时间偏差超过 MaxSkew 的请求视为重放并拒绝。
+// 校验通过后应用ID与用户令牌写入上下文，供后续处理器读取
+func CallbackAuth(cfg *config.CallbackAuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg == nil || !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		appID := c.GetHeader(HeaderAppID)
+		secret, ok := cfg.Apps[appID]
+		if appID == "" || !ok {
+			abort(c, http.StatusUnauthorized, "未知的应用ID")
+			return
+		}
+
+		timestamp := c.GetHeader(HeaderTimestamp)
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			abort(c, http.StatusUnauthorized, "无效的请求时间戳")
+			return
+		}
+		if skew := time.Since(time.Unix(ts, 0)); skew > cfg.MaxSkew || skew < -cfg.MaxSkew {
+			abort(c, http.StatusUnauthorized, "请求时间戳超出允许范围")
+			return
+		}
+
+		token := c.GetHeader(HeaderToken)
+		if token == "" {
+			abort(c, http.StatusUnauthorized, "缺少用户令牌")
+			return
+		}
+
+		// 边读取边计算摘要，较大的请求体（如附件）转存到临时文件而不是缓存在内存中；
+		// 签名校验之前即限制总大小，避免未经认证的请求写满磁盘
+		if c.Request.ContentLength > cfg.MaxBodySize {
+			abort(c, http.StatusRequestEntityTooLarge, "请求体过大")
+			return
+		}
+		body, bodyHash, err := spoolBody(http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abort(c, http.StatusRequestEntityTooLarge, "请求体过大")
+			} else {
+				abort(c, http.StatusBadRequest, "读取请求体失败")
+			}
+			return
+		}
+		defer body.cleanup()
+		c.Request.Body = io.NopCloser(body)
+
+		if !utils.VerifyParts(secret, c.GetHeader(HeaderSignature),
+			c.Request.Method, c.Request.URL.RequestURI(), timestamp, bodyHash, token) {
+			abort(c, http.StatusUnauthorized, "签名校验失败")
+			return
+		}
+
+		c.Set(ContextAppID, appID)
+		c.Set(ContextToken, token)
+		c.Next()
+	}
+}
+
+// spooledBody 已完整读取、可供处理器再次读取的请求体
+type spooledBody struct {
+	io.Reader
+	file *os.File
+}
+
+// cleanup 关闭并删除转存请求体的临时文件
+func (b *spooledBody) cleanup() {
+	if b.file != nil {
+		b.file.Close()
+		os.Remove(b.file.Name())
+	}
+}
+
+// spoolBody 读取请求体并返回其 SHA-256 摘要，
+// 不超过 maxMemoryBodySize 的请求体保留在内存中，其余写入临时文件
+func spoolBody(r io.Reader) (*spooledBody, string, error) {
+	hash := sha256.New()
+	var head bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(&head, hash), io.LimitReader(r, maxMemoryBodySize))
+	if err != nil {
+		return nil, "", err
+	}
+	if n < maxMemoryBodySize {
+		return &spooledBody{Reader: bytes.NewReader(head.Bytes())}, hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	file, err := os.CreateTemp("", "weboffice-body-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("创建请求体临时文件失败: %w", err)
+	}
+	body := &spooledBody{Reader: file, file: file}
+	if _, err := file.Write(head.Bytes()); err != nil {
+		body.cleanup()
+		return nil, "", fmt.Errorf("写入请求体临时文件失败: %w", err)
+	}
+	if _, err := io.Copy(io.MultiWriter(file, hash), r); err != nil {
+		body.cleanup()
+		return nil, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		body.cleanup()
+		return nil, "", fmt.Errorf("读取请求体临时文件失败: %w", err)
+	}
+	return body, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// CallerAppID 返回已通过签名校验的应用ID
+func CallerAppID(c *gin.Context) string {
+	return c.GetString(ContextAppID)
+}
+
+// CallerToken 返回已通过签名校验的用户令牌
+func CallerToken(c *gin.Context) string {
+	return c.GetString(ContextToken)
+}
+
+func abort(c *gin.Context, code int, message string) {
+	utils.ErrorResponse(c, code, message)
+	c.Abort()
+}
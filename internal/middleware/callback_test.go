@@ -0,0 +1,291 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"weboffice/internal/config"
+	"weboffice/internal/utils"
+)
+
+const (
+	testAppID  = "app1"
+	testSecret = "secret1"
+	testToken  = "user-token"
+)
+
+// newCallbackRouter 返回挂载签名校验的路由，处理器原样返回读取到的请求体
+func newCallbackRouter(cfg *config.CallbackAuthConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/v3/3rd/*path", CallbackAuth(cfg), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Header("X-Caller", CallerAppID(c)+"/"+CallerToken(c))
+		c.Data(http.StatusOK, "application/octet-stream", body)
+	})
+	return r
+}
+
+func testCallbackConfig() *config.CallbackAuthConfig {
+	return &config.CallbackAuthConfig{
+		Enabled:     true,
+		Apps:        map[string]string{testAppID: testSecret},
+		MaxSkew:     5 * time.Minute,
+		MaxBodySize: 4 << 20,
+	}
+}
+
+// signedRequest 按 method、uri、body 计算签名，再以 sendURI、sendBody 发送，用于模拟篡改
+func signedRequest(method, uri string, body []byte, ts time.Time, sendURI string, sendBody []byte) *http.Request {
+	sum := sha256.Sum256(body)
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	req := httptest.NewRequest(method, sendURI, bytes.NewReader(sendBody))
+	req.Header.Set(HeaderAppID, testAppID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderToken, testToken)
+	req.Header.Set(HeaderSignature, utils.SignParts(testSecret, method, uri, timestamp, hex.EncodeToString(sum[:]), testToken))
+	return req
+}
+
+func sign(method, uri string, body []byte) *http.Request {
+	return signedRequest(method, uri, body, time.Now(), uri, body)
+}
+
+func TestCallbackAuth(t *testing.T) {
+	const uri = "/v3/3rd/files/f1/upload/prepare?version=2&name=%E6%8A%A5%E5%91%8A.docx"
+	body := []byte(`{"file_id":"f1"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		req      func() *http.Request
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name:     "valid signature",
+			req:      func() *http.Request { return sign(http.MethodPost, uri, body) },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "empty body",
+			req:      func() *http.Request { return sign(http.MethodGet, "/v3/3rd/files/f1", nil) },
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "timestamp within skew",
+			req: func() *http.Request {
+				return signedRequest(http.MethodPost, uri, body, now.Add(-4*time.Minute), uri, body)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "body does not match signed hash",
+			req: func() *http.Request {
+				return signedRequest(http.MethodPost, uri, body, now, uri, []byte(`{"file_id":"f2"}`))
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "签名校验失败",
+		},
+		{
+			name: "query string tampered",
+			req: func() *http.Request {
+				return signedRequest(http.MethodPost, uri, body, now, strings.Replace(uri, "version=2", "version=1", 1), body)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "签名校验失败",
+		},
+		{
+			name: "query parameter appended",
+			req: func() *http.Request {
+				return signedRequest(http.MethodPost, uri, body, now, uri+"&admin=1", body)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "签名校验失败",
+		},
+		{
+			name: "method changed",
+			req: func() *http.Request {
+				req := sign(http.MethodPost, uri, body)
+				req.Method = http.MethodPut
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "签名校验失败",
+		},
+		{
+			name: "token changed",
+			req: func() *http.Request {
+				req := sign(http.MethodPost, uri, body)
+				req.Header.Set(HeaderToken, "other-token")
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "签名校验失败",
+		},
+		{
+			name: "timestamp too old",
+			req: func() *http.Request {
+				return signedRequest(http.MethodPost, uri, body, now.Add(-6*time.Minute), uri, body)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "请求时间戳超出允许范围",
+		},
+		{
+			name: "timestamp in the future",
+			req: func() *http.Request {
+				return signedRequest(http.MethodPost, uri, body, now.Add(6*time.Minute), uri, body)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "请求时间戳超出允许范围",
+		},
+		{
+			name: "invalid timestamp",
+			req: func() *http.Request {
+				req := sign(http.MethodPost, uri, body)
+				req.Header.Set(HeaderTimestamp, "yesterday")
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "无效的请求时间戳",
+		},
+		{
+			name: "unknown app",
+			req: func() *http.Request {
+				req := sign(http.MethodPost, uri, body)
+				req.Header.Set(HeaderAppID, "app2")
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "未知的应用ID",
+		},
+		{
+			name: "missing token",
+			req: func() *http.Request {
+				req := sign(http.MethodPost, uri, body)
+				req.Header.Del(HeaderToken)
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantMsg:  "缺少用户令牌",
+		},
+	}
+
+	router := newCallbackRouter(testCallbackConfig())
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := tc.req()
+			router.ServeHTTP(w, req)
+			if w.Code != tc.wantCode {
+				t.Fatalf("状态码 %d，期望 %d: %s", w.Code, tc.wantCode, w.Body)
+			}
+			if tc.wantMsg != "" && !strings.Contains(w.Body.String(), tc.wantMsg) {
+				t.Errorf("响应 %s 未包含 %q", w.Body, tc.wantMsg)
+			}
+			if tc.wantCode == http.StatusOK && w.Header().Get("X-Caller") != testAppID+"/"+testToken {
+				t.Errorf("上下文中的调用方为 %q", w.Header().Get("X-Caller"))
+			}
+		})
+	}
+}
+
+func TestCallbackAuthBodyReplay(t *testing.T) {
+	// 超过内存缓存上限的请求体转存到临时文件，处理器仍能完整读取，请求结束后临时文件被删除
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	body := bytes.Repeat([]byte("0123456789abcdef"), (maxMemoryBodySize+100)/16)
+
+	for _, size := range []int{0, 100, maxMemoryBodySize, len(body)} {
+		w := httptest.NewRecorder()
+		newCallbackRouter(testCallbackConfig()).ServeHTTP(w, sign(http.MethodPost, "/v3/3rd/object/a", body[:size]))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%d 字节请求体返回 %d: %s", size, w.Code, w.Body)
+		}
+		if !bytes.Equal(w.Body.Bytes(), body[:size]) {
+			t.Errorf("%d 字节请求体未被完整转交处理器", size)
+		}
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("临时文件未删除: %v", entries)
+	}
+}
+
+func TestCallbackAuthBodyLimit(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	cfg := testCallbackConfig()
+	cfg.MaxBodySize = maxMemoryBodySize + 10
+	router := newCallbackRouter(cfg)
+	body := bytes.Repeat([]byte("x"), maxMemoryBodySize+11)
+
+	tests := []struct {
+		name     string
+		req      func() *http.Request
+		wantCode int
+	}{
+		{
+			name:     "at the limit",
+			req:      func() *http.Request { return sign(http.MethodPost, "/v3/3rd/object/a", body[:cfg.MaxBodySize]) },
+			wantCode: http.StatusOK,
+		},
+		{
+			// 声明的长度超出上限时不读取请求体，签名无论是否正确都返回 413
+			name: "declared length over the limit",
+			req: func() *http.Request {
+				req := sign(http.MethodPost, "/v3/3rd/object/a", body)
+				req.Header.Set(HeaderSignature, "invalid")
+				return req
+			},
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			// 未声明长度的请求体在读取时截断
+			name: "chunked body over the limit",
+			req: func() *http.Request {
+				req := sign(http.MethodPost, "/v3/3rd/object/a", body)
+				req.ContentLength = -1
+				return req
+			},
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tc.req())
+			if w.Code != tc.wantCode {
+				t.Fatalf("状态码 %d，期望 %d: %s", w.Code, tc.wantCode, w.Body)
+			}
+		})
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("临时文件未删除: %v", entries)
+	}
+}
+
+func TestCallbackAuthDisabled(t *testing.T) {
+	for _, cfg := range []*config.CallbackAuthConfig{nil, {Enabled: false}} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v3/3rd/files/f1", strings.NewReader("body"))
+		newCallbackRouter(cfg).ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != "body" {
+			t.Errorf("未启用签名校验时返回 %d: %s", w.Code, w.Body)
+		}
+	}
+}
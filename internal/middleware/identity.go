@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"weboffice/internal/config"
+	"weboffice/internal/models"
+)
+
+// ContextIdentity 上下文中保存调用者身份的键
+const ContextIdentity = "weboffice.identity"
+
+// ErrInvalidToken 令牌无法解析为有效用户
+var ErrInvalidToken = errors.New("无效的用户令牌")
+
+// Identity 已认证的调用者身份
+type Identity struct {
+	UserID    string
+	ShareLink *models.ShareLink // 通过分享链接访问时非空
+}
+
+// IdentityResolver 将 WebOffice 用户令牌解析为调用者身份，
+// 令牌无效时返回 ErrInvalidToken
+type IdentityResolver interface {
+	Resolve(token string) (*Identity, error)
+}
+
+// StaticResolver 基于配置中固定令牌表的解析器
+type StaticResolver map[string]string
+
+func (r StaticResolver) Resolve(token string) (*Identity, error) {
+	userID, ok := r[token]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return &Identity{UserID: userID}, nil
+}
+
+// DBResolver 基于 user_tokens 表的解析器
+type DBResolver struct {
+	DB *gorm.DB
+}
+
+func (r *DBResolver) Resolve(token string) (*Identity, error) {
+	var userToken models.UserToken
+	if err := r.DB.Where("token = ?", token).First(&userToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("查询用户令牌失败: %w", err)
+	}
+	if userToken.ExpireTime > 0 && time.Now().Unix() > userToken.ExpireTime {
+		return nil, ErrInvalidToken
+	}
+	return &Identity{UserID: userToken.UserID}, nil
+}
+
+// NewIdentityResolver 根据配置创建令牌解析器，分享令牌始终由 ShareResolver 处理
+func NewIdentityResolver(cfg *config.AppConfig, db *gorm.DB) (IdentityResolver, error) {
+	var next IdentityResolver
+	switch {
+	case cfg.Identity == nil, cfg.Identity.Resolver == "", cfg.Identity.Resolver == "db":
+		next = &DBResolver{DB: db}
+	case cfg.Identity.Resolver == "static":
+		next = StaticResolver(cfg.Identity.StaticTokens)
+	default:
+		return nil, fmt.Errorf("不支持的身份解析器: %s", cfg.Identity.Resolver)
+	}
+	return &ShareResolver{Next: next, DB: db, SignKey: cfg.URLSignKey}, nil
+}
+
+// Authenticate 将回调中的用户令牌解析为调用者身份并写入上下文，
+// 需位于 CallbackAuth 之后；签名校验关闭时直接读取令牌请求头
+func Authenticate(resolver IdentityResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := CallerToken(c)
+		if token == "" {
+			token = c.GetHeader(HeaderToken)
+		}
+		if token == "" {
+			abort(c, http.StatusUnauthorized, "缺少用户令牌")
+			return
+		}
+
+		identity, err := resolver.Resolve(token)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				abort(c, http.StatusUnauthorized, err.Error())
+			} else {
+				log.Printf("解析用户身份失败: %v", err)
+				abort(c, http.StatusInternalServerError, "身份解析失败")
+			}
+			return
+		}
+
+		c.Set(ContextIdentity, identity)
+		c.Next()
+	}
+}
+
+// CurrentIdentity 返回当前请求的调用者身份，未认证时返回 nil
+func CurrentIdentity(c *gin.Context) *Identity {
+	if v, ok := c.Get(ContextIdentity); ok {
+		if identity, ok := v.(*Identity); ok {
+			return identity
+		}
+	}
+	return nil
+}
+
+// CurrentUserID 返回当前请求的用户ID，未认证时返回空字符串
+func CurrentUserID(c *gin.Context) string {
+	if identity := CurrentIdentity(c); identity != nil {
+		return identity.UserID
+	}
+	return ""
+}
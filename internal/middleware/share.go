@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"weboffice/internal/models"
+	"weboffice/internal/utils"
+)
+
+// 分享令牌前缀：通过分享链接换取的 WebOffice 令牌形如 share.{链接ID}.{过期时间}.{签名}
+const shareTokenPrefix = "share."
+
+// ShareUserPrefix 分享访客的用户ID前缀，完整形式为 share:{链接ID}
+const ShareUserPrefix = "share:"
+
+// IssueShareToken 为分享链接签发 WebOffice 令牌
+func IssueShareToken(signKey, linkID string, expires int64) string {
+	exp := strconv.FormatInt(expires, 10)
+	return shareTokenPrefix + linkID + "." + exp + "." + utils.SignParts(signKey, "share", linkID, exp)
+}
+
+// ShareLinkID 从分享访客的用户ID中取出链接ID，非分享访客返回 false
+func ShareLinkID(userID string) (string, bool) {
+	if !strings.HasPrefix(userID, ShareUserPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(userID, ShareUserPrefix), true
+}
+
+// LoadActiveShareLink 加载未撤销且未过期的分享链接，不可用时返回 ErrInvalidToken
+func LoadActiveShareLink(db *gorm.DB, linkID string) (*models.ShareLink, error) {
+	var link models.ShareLink
+	if err := db.Where("id = ?", linkID).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("查询分享链接失败: %w", err)
+	}
+	if !link.Active(time.Now().Unix()) {
+		return nil, ErrInvalidToken
+	}
+	return &link, nil
+}
+
+// ShareResolver 识别分享令牌并解析为分享访客身份，其余令牌交由 Next 解析
+type ShareResolver struct {
+	Next    IdentityResolver
+	DB      *gorm.DB
+	SignKey string
+}
+
+func (r *ShareResolver) Resolve(token string) (*Identity, error) {
+	if !strings.HasPrefix(token, shareTokenPrefix) {
+		return r.Next.Resolve(token)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, shareTokenPrefix), ".")
+	if len(parts) != 3 || !utils.VerifyParts(r.SignKey, parts[2], "share", parts[0], parts[1]) {
+		return nil, ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, ErrInvalidToken
+	}
+
+	// 每次回调都重新检查链接状态，撤销立即生效
+	link, err := LoadActiveShareLink(r.DB, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{UserID: ShareUserPrefix + link.ID, ShareLink: link}, nil
+}
@@ -0,0 +1,160 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0001 基线结构：与引入版本化迁移前 AutoMigrate 生成的表结构一致。
+// 此处的结构体为当时模型的冻结副本，后续模型变更不得修改，应新增迁移。
+
+type baselineFile struct {
+	ID         string `gorm:"primaryKey;type:char(36)"`
+	Name       string `gorm:"size:240"`
+	Version    int    `gorm:"not null;default:0"`
+	Size       int    `gorm:"not null"`
+	CreateTime int64  `gorm:"not null"`
+	ModifyTime int64  `gorm:"not null"`
+	CreatorID  string `gorm:"size:48;not null"`
+	ModifierID string `gorm:"size:48;not null"`
+	OwnerID    string `gorm:"size:48;index"`
+}
+
+func (baselineFile) TableName() string { return "files" }
+
+type baselineFileVersion struct {
+	ID         string `gorm:"primaryKey;size:47;index:idx_file_versions"`
+	Version    int    `gorm:"primaryKey;not null;index:idx_file_versions"`
+	Name       string `gorm:"size:240"`
+	Size       int    `gorm:"not null"`
+	CreateTime int64  `gorm:"not null"`
+	ModifierID string `gorm:"size:48;not null"`
+}
+
+func (baselineFileVersion) TableName() string { return "file_versions" }
+
+type baselineUser struct {
+	ID        string `gorm:"primaryKey;size:48"`
+	Name      string `gorm:"size:100"`
+	AvatarURL string `gorm:"size:200"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineUserToken struct {
+	Token      string `gorm:"primaryKey;size:128"`
+	UserID     string `gorm:"size:48;not null;index"`
+	CreateTime int64  `gorm:"not null"`
+	ExpireTime int64  `gorm:"not null;default:0"`
+}
+
+func (baselineUserToken) TableName() string { return "user_tokens" }
+
+type baselineFileACL struct {
+	ID          uint   `gorm:"primaryKey"`
+	FileID      string `gorm:"size:47;not null;uniqueIndex:idx_file_acl_subject"`
+	SubjectType string `gorm:"size:16;not null;uniqueIndex:idx_file_acl_subject"`
+	SubjectID   string `gorm:"size:48;not null;uniqueIndex:idx_file_acl_subject"`
+	Permissions int    `gorm:"not null"`
+	GrantedBy   string `gorm:"size:48;not null"`
+	CreateTime  int64  `gorm:"not null"`
+}
+
+func (baselineFileACL) TableName() string { return "file_acls" }
+
+type baselineShareLink struct {
+	ID           string `gorm:"primaryKey;type:char(36)"`
+	FileID       string `gorm:"size:47;not null;index"`
+	Token        string `gorm:"size:64;not null;uniqueIndex"`
+	Scope        string `gorm:"size:16;not null"`
+	PasswordHash string `gorm:"size:100"`
+	MaxUses      int    `gorm:"not null;default:0"`
+	UseCount     int    `gorm:"not null;default:0"`
+	ExpireTime   int64  `gorm:"not null;default:0"`
+	CreatorID    string `gorm:"size:48;not null"`
+	CreateTime   int64  `gorm:"not null"`
+	RevokeTime   int64  `gorm:"not null;default:0"`
+}
+
+func (baselineShareLink) TableName() string { return "share_links" }
+
+type baselineGroupMember struct {
+	GroupID string `gorm:"primaryKey;size:48"`
+	UserID  string `gorm:"primaryKey;size:48;index"`
+}
+
+func (baselineGroupMember) TableName() string { return "group_members" }
+
+type baselineWatermark struct {
+	FileID     string `gorm:"primaryKey;size:47"`
+	Type       int    `gorm:"not null"`
+	Value      string `gorm:"size:200"`
+	Horizontal int    `gorm:"not null"`
+	Vertical   int    `gorm:"not null"`
+}
+
+func (baselineWatermark) TableName() string { return "watermarks" }
+
+// Data 不指定列类型，由方言选择（MySQL longblob、PostgreSQL bytea、SQLite blob）
+type baselineAttachment struct {
+	Key       string `gorm:"primaryKey;size:100"`
+	Data      []byte
+	CreatedAt int64 `gorm:"not null"`
+}
+
+func (baselineAttachment) TableName() string { return "attachments" }
+
+type baselineUploadSession struct {
+	ID           string `gorm:"primaryKey;type:char(36)"`
+	FileID       string `gorm:"size:47;not null;index"`
+	Status       string `gorm:"size:16;not null;index"`
+	Name         string `gorm:"size:240"`
+	Size         int64  `gorm:"not null;default:0"`
+	DeclaredSha1 string `gorm:"size:40"`
+	DeclaredMd5  string `gorm:"size:32"`
+	ReceivedSize int64  `gorm:"not null;default:0"`
+	Sha1         string `gorm:"size:40"`
+	Md5          string `gorm:"size:32"`
+	CreateTime   int64  `gorm:"not null"`
+	UpdateTime   int64  `gorm:"not null"`
+	ExpireTime   int64  `gorm:"not null;index"`
+}
+
+func (baselineUploadSession) TableName() string { return "upload_sessions" }
+
+var baselineTables = []interface{}{
+	&baselineFile{},
+	&baselineFileVersion{},
+	&baselineUser{},
+	&baselineWatermark{},
+	&baselineAttachment{},
+	&baselineUploadSession{},
+	&baselineUserToken{},
+	&baselineFileACL{},
+	&baselineGroupMember{},
+	&baselineShareLink{},
+}
+
+// baseline 只创建不存在的表，已由 AutoMigrate 建好的旧库可直接纳入版本管理
+var baseline = Migration{
+	Version: 1,
+	Name:    "baseline",
+	Up: func(tx *gorm.DB) error {
+		migrator := withTableOptions(tx).Migrator()
+		for _, table := range baselineTables {
+			if migrator.HasTable(table) {
+				continue
+			}
+			if err := migrator.CreateTable(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for i := len(baselineTables) - 1; i >= 0; i-- {
+			if err := migrator.DropTable(baselineTables[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 0002 将 file_versions.id 与 files.id 对齐为 char(36)（原为 varchar(47)）
+
+type fileVersionIDChar36 struct {
+	ID      string `gorm:"primaryKey;type:char(36);index:idx_file_versions"`
+	Version int    `gorm:"primaryKey;not null;index:idx_file_versions"`
+}
+
+func (fileVersionIDChar36) TableName() string { return "file_versions" }
+
+var fileVersionIDLength = Migration{
+	Version: 2,
+	Name:    "file_version_id_char36",
+	Up: func(tx *gorm.DB) error {
+		var tooLong int64
+		if err := tx.Table("file_versions").Where("LENGTH(id) > ?", 36).Count(&tooLong).Error; err != nil {
+			return err
+		}
+		if tooLong > 0 {
+			return fmt.Errorf("file_versions 中有 %d 条记录的 id 超过 36 个字符", tooLong)
+		}
+		return alterFileVersionID(tx, &fileVersionIDChar36{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return alterFileVersionID(tx, &baselineFileVersion{})
+	},
+}
+
+// alterFileVersionID 修改 id 列类型；SQLite 下通过重建表实现，
+// 会保留其余列与联合主键但丢失二级索引，需要补建
+func alterFileVersionID(tx *gorm.DB, model interface{}) error {
+	migrator := tx.Migrator()
+	if err := migrator.AlterColumn(model, "ID"); err != nil {
+		return err
+	}
+	if !migrator.HasIndex(model, "idx_file_versions") {
+		return migrator.CreateIndex(model, "idx_file_versions")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0003 记录上传内容中检测到的主动内容（宏、嵌入对象、外部链接），上传会话增加隔离原因与暂存大小
+
+type activeContentFileVersion struct {
+	HasMacros           bool   `gorm:"not null;default:false"`
+	HasEmbeddedObjects  bool   `gorm:"not null;default:false"`
+	HasExternalLinks    bool   `gorm:"not null;default:false"`
+	ActiveContentAction string `gorm:"size:16"`
+}
+
+func (activeContentFileVersion) TableName() string { return "file_versions" }
+
+type activeContentUploadSession struct {
+	StoredSize          int64  `gorm:"not null;default:0"`
+	Reason              string `gorm:"size:255"`
+	HasMacros           bool   `gorm:"not null;default:false"`
+	HasEmbeddedObjects  bool   `gorm:"not null;default:false"`
+	HasExternalLinks    bool   `gorm:"not null;default:false"`
+	ActiveContentAction string `gorm:"size:16"`
+}
+
+func (activeContentUploadSession) TableName() string { return "upload_sessions" }
+
+var (
+	activeContentFields = []string{"HasMacros", "HasEmbeddedObjects", "HasExternalLinks", "ActiveContentAction"}
+	uploadSessionFields = append([]string{"StoredSize", "Reason"}, activeContentFields...)
+)
+
+var activeContent = Migration{
+	Version: 3,
+	Name:    "active_content",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &activeContentFileVersion{}, activeContentFields...); err != nil {
+			return err
+		}
+		return addColumns(tx, &activeContentUploadSession{}, uploadSessionFields...)
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropColumns(tx, &activeContentUploadSession{}, uploadSessionFields...); err != nil {
+			return err
+		}
+		return dropColumns(tx, &activeContentFileVersion{}, activeContentFields...)
+	},
+}
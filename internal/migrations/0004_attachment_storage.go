@@ -0,0 +1,119 @@
+package migrations
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weboffice/internal/storage"
+)
+
+// 0004 附件内容由 attachments.data 列迁移到存储后端，表中只保留键、大小、摘要、内容类型与存储位置
+
+type attachmentStorageAttachment struct {
+	Key         string `gorm:"primaryKey;size:100"`
+	Data        []byte
+	Size        int64  `gorm:"not null;default:0"`
+	Md5         string `gorm:"size:32"`
+	Sha1        string `gorm:"size:40"`
+	ContentType string `gorm:"size:100"`
+	StorageKey  string `gorm:"size:100"`
+	CreatedAt   int64  `gorm:"not null"`
+}
+
+func (attachmentStorageAttachment) TableName() string { return "attachments" }
+
+var attachmentMetaFields = []string{"Size", "Md5", "Sha1", "ContentType", "StorageKey"}
+
+// errStorageRequired 迁移需要读写存储后端
+var errStorageRequired = errors.New("该迁移需要访问存储后端")
+
+func attachmentStorage(store storage.Storage) Migration {
+	return Migration{
+		Version: 4,
+		Name:    "attachment_storage",
+		Up: func(tx *gorm.DB) error {
+			if store == nil {
+				return errStorageRequired
+			}
+			model := &attachmentStorageAttachment{}
+			if err := addColumns(tx, model, attachmentMetaFields...); err != nil {
+				return err
+			}
+
+			// 逐行读取，避免一次性将全部附件内容载入内存
+			var keys []string
+			if err := tx.Model(model).Where("storage_key IS NULL OR storage_key = ''").
+				Pluck("key", &keys).Error; err != nil {
+				return err
+			}
+			for _, key := range keys {
+				var row attachmentStorageAttachment
+				if err := tx.Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key}).
+					First(&row).Error; err != nil {
+					return err
+				}
+				storageKey := uuid.New().String()
+				if _, err := store.PutObject(storageKey, bytes.NewReader(row.Data)); err != nil {
+					return fmt.Errorf("迁移附件 %s 失败: %w", key, err)
+				}
+				md5Sum, sha1Sum := md5.Sum(row.Data), sha1.Sum(row.Data)
+				if err := tx.Model(model).Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key}).
+					Updates(map[string]interface{}{
+						"size":         int64(len(row.Data)),
+						"md5":          hex.EncodeToString(md5Sum[:]),
+						"sha1":         hex.EncodeToString(sha1Sum[:]),
+						"content_type": http.DetectContentType(row.Data),
+						"storage_key":  storageKey,
+					}).Error; err != nil {
+					return err
+				}
+			}
+			return dropColumns(tx, model, "Data")
+		},
+		// Down 将内容读回 data 列；存储后端中的对象保留，由垃圾回收清理
+		Down: func(tx *gorm.DB) error {
+			if store == nil {
+				return errStorageRequired
+			}
+			model := &attachmentStorageAttachment{}
+			if err := addColumns(tx, model, "Data"); err != nil {
+				return err
+			}
+
+			var rows []attachmentStorageAttachment
+			if err := tx.Select("key", "storage_key").Where("storage_key <> ''").Find(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				data, err := readObject(store, row.StorageKey)
+				if err != nil {
+					return fmt.Errorf("回滚附件 %s 失败: %w", row.Key, err)
+				}
+				if err := tx.Model(model).Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: row.Key}).
+					Update("data", data).Error; err != nil {
+					return err
+				}
+			}
+			return dropColumns(tx, model, attachmentMetaFields...)
+		},
+	}
+}
+
+func readObject(store storage.Storage, key string) ([]byte, error) {
+	rc, err := store.GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
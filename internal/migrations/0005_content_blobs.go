@@ -0,0 +1,172 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weboffice/internal/storage"
+)
+
+// 0005 按 SHA-256 寻址的内容（blobs 表及引用计数），版本、附件与上传会话记录内容摘要；
+// 已有附件按内容重新寻址，原对象留待垃圾回收
+
+type contentBlob struct {
+	Sha256    string `gorm:"primaryKey;size:64"`
+	Size      int64  `gorm:"not null"`
+	RefCount  int64  `gorm:"not null;default:0;index"`
+	CreatedAt int64  `gorm:"not null"`
+	UpdatedAt int64  `gorm:"not null"`
+}
+
+func (contentBlob) TableName() string { return "blobs" }
+
+type contentBlobFileVersion struct {
+	ID      string `gorm:"primaryKey;type:char(36)"`
+	Version int    `gorm:"primaryKey"`
+	Name    string `gorm:"size:240"`
+	Sha256  string `gorm:"size:64;index"`
+}
+
+func (contentBlobFileVersion) TableName() string { return "file_versions" }
+
+type contentBlobAttachment struct {
+	Key        string `gorm:"primaryKey;size:100"`
+	Sha256     string `gorm:"size:64;index"`
+	StorageKey string `gorm:"size:100"`
+}
+
+func (contentBlobAttachment) TableName() string { return "attachments" }
+
+type contentBlobUploadSession struct {
+	Sha256 string `gorm:"size:64"`
+}
+
+func (contentBlobUploadSession) TableName() string { return "upload_sessions" }
+
+func contentBlobs(store storage.Storage) Migration {
+	return Migration{
+		Version: 5,
+		Name:    "content_blobs",
+		Up: func(tx *gorm.DB) error {
+			if store == nil {
+				return errStorageRequired
+			}
+			if err := withTableOptions(tx).Migrator().CreateTable(&contentBlob{}); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &contentBlobUploadSession{}, "Sha256"); err != nil {
+				return err
+			}
+			for _, model := range []interface{}{&contentBlobFileVersion{}, &contentBlobAttachment{}} {
+				if err := addColumns(tx, model, "Sha256"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(model, "Sha256"); err != nil {
+					return err
+				}
+			}
+
+			var attachments []contentBlobAttachment
+			if err := tx.Where("sha256 IS NULL OR sha256 = ''").Find(&attachments).Error; err != nil {
+				return err
+			}
+			for _, attachment := range attachments {
+				sum, err := rehashObject(tx, store, attachment.StorageKey)
+				if err != nil {
+					return fmt.Errorf("迁移附件 %s 失败: %w", attachment.Key, err)
+				}
+				if err := tx.Model(&contentBlobAttachment{}).
+					Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: attachment.Key}).
+					Updates(map[string]interface{}{"sha256": sum, "storage_key": sum}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// Down 将按摘要寻址的版本内容写回按版本号存储的位置；附件的对象键已是摘要，无需搬移
+		Down: func(tx *gorm.DB) error {
+			if store == nil {
+				return errStorageRequired
+			}
+			var versions []contentBlobFileVersion
+			if err := tx.Where("sha256 <> ''").Find(&versions).Error; err != nil {
+				return err
+			}
+			for _, v := range versions {
+				if err := copyBlobToVersion(store, v); err != nil {
+					return fmt.Errorf("回滚版本 %s/v%d 失败: %w", v.ID, v.Version, err)
+				}
+			}
+
+			if err := dropColumns(tx, &contentBlobAttachment{}, "Sha256"); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &contentBlobFileVersion{}, "Sha256"); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &contentBlobUploadSession{}, "Sha256"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&contentBlob{})
+		},
+	}
+}
+
+// rehashObject 计算对象内容的 SHA-256，以摘要为键写入（内容已存在时只增加引用）并返回摘要
+func rehashObject(tx *gorm.DB, store storage.Storage, key string) (string, error) {
+	rc, err := store.GetObject(key)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	spool, err := os.CreateTemp("", "weboffice-migrate-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), rc)
+	if err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	now := time.Now().Unix()
+	result := tx.Model(&contentBlob{}).Where("sha256 = ?", sum).Updates(map[string]interface{}{
+		"ref_count":  gorm.Expr("ref_count + 1"),
+		"updated_at": now,
+	})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected > 0 {
+		return sum, nil
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := store.PutObject(sum, spool); err != nil {
+		return "", err
+	}
+	return sum, tx.Create(&contentBlob{Sha256: sum, Size: size, RefCount: 1, CreatedAt: now, UpdatedAt: now}).Error
+}
+
+func copyBlobToVersion(store storage.Storage, v contentBlobFileVersion) error {
+	rc, err := store.GetObject(v.Sha256)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return store.SaveFile(v.ID, v.Version, v.Name, rc)
+}
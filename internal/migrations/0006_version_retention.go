@@ -0,0 +1,40 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0006 版本保留策略：版本可固定（不被清理），文件可单独设置保留策略
+
+type versionRetentionFileVersion struct {
+	Pinned bool `gorm:"not null;default:false"`
+}
+
+func (versionRetentionFileVersion) TableName() string { return "file_versions" }
+
+type versionRetentionPolicy struct {
+	FileID     string `gorm:"primaryKey;size:47"`
+	KeepLast   int    `gorm:"not null;default:0"`
+	KeepDays   int    `gorm:"not null;default:0"`
+	KeepDaily  int    `gorm:"not null;default:0"`
+	KeepWeekly int    `gorm:"not null;default:0"`
+	UpdatedBy  string `gorm:"size:48;not null"`
+	UpdateTime int64  `gorm:"not null"`
+}
+
+func (versionRetentionPolicy) TableName() string { return "file_retentions" }
+
+var versionRetention = Migration{
+	Version: 6,
+	Name:    "version_retention",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &versionRetentionFileVersion{}, "Pinned"); err != nil {
+			return err
+		}
+		return withTableOptions(tx).Migrator().CreateTable(&versionRetentionPolicy{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropTable(&versionRetentionPolicy{}); err != nil {
+			return err
+		}
+		return dropColumns(tx, &versionRetentionFileVersion{}, "Pinned")
+	},
+}
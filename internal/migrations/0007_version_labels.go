@@ -0,0 +1,23 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0007 版本可标注里程碑标签与说明
+
+type versionLabelsFileVersion struct {
+	Label   string `gorm:"size:100"`
+	Comment string `gorm:"size:1000"`
+}
+
+func (versionLabelsFileVersion) TableName() string { return "file_versions" }
+
+var versionLabels = Migration{
+	Version: 7,
+	Name:    "version_labels",
+	Up: func(tx *gorm.DB) error {
+		return addColumns(tx, &versionLabelsFileVersion{}, "Label", "Comment")
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropColumns(tx, &versionLabelsFileVersion{}, "Label", "Comment")
+	},
+}
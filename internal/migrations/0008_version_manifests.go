@@ -0,0 +1,139 @@
+package migrations
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"gorm.io/gorm"
+
+	"weboffice/internal/storage"
+	"weboffice/internal/utils"
+)
+
+// 0008 版本内容清单（存储键、摘要、内容类型、原始文件名）；已有版本按内容位置建立清单：
+// 按摘要寻址的版本指向对应 Blob，按版本号存储的版本在存储中查找实际保存的文件
+
+type versionManifestFileVersion struct {
+	ID           string `gorm:"primaryKey;type:char(36)"`
+	Version      int    `gorm:"primaryKey"`
+	Name         string `gorm:"size:240"`
+	Sha256       string `gorm:"size:64"`
+	StorageKey   string `gorm:"size:300"`
+	Sha1         string `gorm:"size:40"`
+	Md5          string `gorm:"size:32"`
+	ContentType  string `gorm:"size:127"`
+	OriginalName string `gorm:"size:240"`
+}
+
+func (versionManifestFileVersion) TableName() string { return "file_versions" }
+
+type versionManifestUploadSession struct {
+	StoredSha1 string `gorm:"size:40"`
+	StoredMd5  string `gorm:"size:32"`
+}
+
+func (versionManifestUploadSession) TableName() string { return "upload_sessions" }
+
+var (
+	versionManifestFields       = []string{"StorageKey", "Sha1", "Md5", "ContentType", "OriginalName"}
+	versionManifestSessionField = []string{"StoredSha1", "StoredMd5"}
+)
+
+func versionManifests(store storage.Storage) Migration {
+	return Migration{
+		Version: 8,
+		Name:    "version_manifests",
+		Up: func(tx *gorm.DB) error {
+			if store == nil {
+				return errStorageRequired
+			}
+			if err := addColumns(tx, &versionManifestFileVersion{}, versionManifestFields...); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &versionManifestUploadSession{}, versionManifestSessionField...); err != nil {
+				return err
+			}
+
+			// 按版本号存储的内容，文件名以实际保存的为准
+			infos, err := store.ListAllVersions()
+			if err != nil {
+				return fmt.Errorf("列出存储中的版本失败: %w", err)
+			}
+			onDisk := make(map[string]storage.FileInfo, len(infos))
+			for _, info := range infos {
+				onDisk[fmt.Sprintf("%s/v%d", info.FileID, info.Version)] = info
+			}
+
+			// 多个版本可能引用同一 Blob，摘要只计算一次
+			type digests struct{ sha1, md5 string }
+			digested := make(map[string]digests)
+
+			var versions []versionManifestFileVersion
+			if err := tx.Where("storage_key IS NULL OR storage_key = ''").Find(&versions).Error; err != nil {
+				return err
+			}
+			for _, v := range versions {
+				key := storage.ObjectStorageKey(v.Sha256)
+				if v.Sha256 == "" {
+					info, ok := onDisk[fmt.Sprintf("%s/v%d", v.ID, v.Version)]
+					if !ok || info.Key == "" {
+						log.Printf("版本 %s/v%d 在存储中没有唯一对应的内容，未建立清单", v.ID, v.Version)
+						continue
+					}
+					key = info.Key
+				}
+
+				updates := map[string]interface{}{
+					"storage_key":   key,
+					"content_type":  utils.ContentTypeByName(v.Name),
+					"original_name": v.Name,
+				}
+				d, ok := digested[key]
+				if !ok {
+					d.sha1, d.md5, err = digestStorageKey(store, key)
+				}
+				switch {
+				case errors.Is(err, storage.ErrNotFound):
+					log.Printf("版本 %s/v%d 的内容 %s 不存在，清单中不含摘要", v.ID, v.Version, key)
+				case err != nil:
+					return fmt.Errorf("读取版本 %s/v%d 失败: %w", v.ID, v.Version, err)
+				default:
+					digested[key] = d
+					updates["sha1"], updates["md5"] = d.sha1, d.md5
+				}
+				if err := tx.Model(&versionManifestFileVersion{}).
+					Where("id = ? AND version = ?", v.ID, v.Version).
+					Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &versionManifestUploadSession{}, versionManifestSessionField...); err != nil {
+				return err
+			}
+			return dropColumns(tx, &versionManifestFileVersion{}, versionManifestFields...)
+		},
+	}
+}
+
+// digestStorageKey 计算存储键对应内容的 SHA-1 与 MD5
+func digestStorageKey(store storage.Storage, key string) (string, string, error) {
+	rc, err := store.GetFile(key)
+	if err != nil {
+		return "", "", err
+	}
+	defer rc.Close()
+
+	sha1Hash, md5Hash := sha1.New(), md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha1Hash, md5Hash), rc); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sha1Hash.Sum(nil)), hex.EncodeToString(md5Hash.Sum(nil)), nil
+}
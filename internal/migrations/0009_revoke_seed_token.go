@@ -0,0 +1,17 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0009 吊销早期版本启动时写入的固定测试令牌 user1-token。
+// 回滚不恢复该令牌：这是公开已知的凭据，任何结构版本下都不应存在
+
+var revokeSeedToken = Migration{
+	Version: 9,
+	Name:    "revoke_seed_token",
+	Up: func(tx *gorm.DB) error {
+		return tx.Exec("DELETE FROM user_tokens WHERE token = ?", "user1-token").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
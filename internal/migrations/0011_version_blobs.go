@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0011 已有的按版本号存储的版本内容改为按 SHA-256 寻址，相同内容只保存一份，
+// 原内容留待垃圾回收。只迁移数据：回滚后这些版本仍是 0010 结构下的有效数据，无需撤销
+
+type versionBlobsBlob struct {
+	Sha256    string `gorm:"primaryKey;size:64"`
+	Size      int64  `gorm:"not null"`
+	RefCount  int64  `gorm:"not null;default:0;index"`
+	CreatedAt int64  `gorm:"not null"`
+	UpdatedAt int64  `gorm:"not null"`
+}
+
+func (versionBlobsBlob) TableName() string { return "blobs" }
+
+type versionBlobsFileVersion struct {
+	ID         string `gorm:"primaryKey;type:char(36)"`
+	Version    int    `gorm:"primaryKey"`
+	Sha256     string `gorm:"size:64;index"`
+	StorageKey string `gorm:"size:300"`
+}
+
+func (versionBlobsFileVersion) TableName() string { return "file_versions" }
+
+func versionBlobs(store contentStore) Migration {
+	return Migration{
+		Version: 11,
+		Name:    "version_blobs",
+		Up: func(tx *gorm.DB) error {
+			if store == nil {
+				return errStorageRequired
+			}
+
+			var versions []versionBlobsFileVersion
+			if err := tx.Where("(sha256 IS NULL OR sha256 = '') AND storage_key <> ''").
+				Find(&versions).Error; err != nil {
+				return err
+			}
+			for _, v := range versions {
+				sum, err := storeVersionBlob(tx, store, v.StorageKey)
+				if errors.Is(err, errContentNotFound) {
+					log.Printf("版本 %s/v%d 的内容 %s 不存在，未按摘要寻址", v.ID, v.Version, v.StorageKey)
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("迁移版本 %s/v%d 失败: %w", v.ID, v.Version, err)
+				}
+				if err := tx.Model(&versionBlobsFileVersion{}).
+					Where("id = ? AND version = ?", v.ID, v.Version).
+					Updates(map[string]interface{}{"sha256": sum, "storage_key": store.blobKey(sum)}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	}
+}
+
+// storeVersionBlob 计算 key 对应内容的 SHA-256，以摘要为键写入（内容已存在时只增加引用）并返回摘要
+func storeVersionBlob(tx *gorm.DB, store contentStore, key string) (string, error) {
+	rc, err := store.getContent(key)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	spool, err := os.CreateTemp("", "weboffice-migrate-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), rc)
+	if err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	now := time.Now().Unix()
+	result := tx.Model(&versionBlobsBlob{}).Where("sha256 = ?", sum).Updates(map[string]interface{}{
+		"ref_count":  gorm.Expr("ref_count + 1"),
+		"updated_at": now,
+	})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected > 0 {
+		return sum, nil
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := store.putObject(sum, spool); err != nil {
+		return "", err
+	}
+	return sum, tx.Create(&versionBlobsBlob{Sha256: sum, Size: size, RefCount: 1, CreatedAt: now, UpdatedAt: now}).Error
+}
@@ -0,0 +1,272 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"weboffice/internal/storage"
+)
+
+// Migration 一次带编号的结构迁移，Up/Down 必须互为逆操作
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration schema_migrations 表中已执行迁移的记录
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:100;not null"`
+	AppliedAt int64  `gorm:"not null"`
+}
+
+// MigrationStatus 迁移执行状态
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt int64
+}
+
+// All 返回按版本号升序排列的全部迁移，store 供需要搬移内容的数据迁移使用
+func All(store storage.Storage) []Migration {
+	// 0010 起的数据迁移经 contentStore 访问存储，storage.Storage 后续变更不影响这些迁移
+	content := newContentStore(store)
+	all := []Migration{
+		baseline,
+		fileVersionIDLength,
+		activeContent,
+		attachmentStorage(store),
+		contentBlobs(store),
+		versionRetention,
+		versionLabels,
+		versionManifests(store),
+		revokeSeedToken,
+		versionDigests(content),
+		versionBlobs(content),
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// ErrSchemaOutdated 数据库结构版本落后于程序
+var ErrSchemaOutdated = errors.New("数据库结构未迁移到最新版本")
+
+// Runner 执行并记录结构迁移
+type Runner struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewRunner 创建迁移执行器；仅检查迁移状态时 store 可为 nil
+func NewRunner(db *gorm.DB, store storage.Storage) *Runner {
+	return &Runner{db: db, migrations: All(store)}
+}
+
+// applied 返回已执行的迁移记录（按版本号索引）
+func (r *Runner) applied() (map[int]SchemaMigration, error) {
+	if err := r.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+	}
+	var records []SchemaMigration
+	if err := r.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("读取迁移记录失败: %w", err)
+	}
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// Status 列出全部迁移及其执行状态
+func (r *Runner) Status() ([]MigrationStatus, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		record, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: record.AppliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// Up 按顺序执行未执行的迁移，steps <= 0 时执行全部，返回本次执行的迁移
+func (r *Runner) Up(steps int) ([]Migration, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now().Unix(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("执行迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down 按倒序回滚最近执行的 steps 个迁移，返回本次回滚的迁移
+func (r *Runner) Down(steps int) ([]Migration, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := r.migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("回滚迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// EnsureCurrent 确认数据库已执行全部迁移且不包含程序未知的迁移
+func (r *Runner) EnsureCurrent() error {
+	applied, err := r.applied()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	known := make(map[int]bool, len(r.migrations))
+	for _, m := range r.migrations {
+		known[m.Version] = true
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, fmt.Sprintf("%04d_%s", m.Version, m.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w，待执行: %s（请先运行 weboffice migrate up）",
+			ErrSchemaOutdated, strings.Join(pending, ", "))
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("数据库包含未知的迁移版本 %04d，程序版本可能过旧", version)
+		}
+	}
+	return nil
+}
+
+// addColumns 为表添加模型中的指定字段，已存在的列跳过
+func addColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if migrator.HasColumn(model, field) {
+			continue
+		}
+		if err := migrator.AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumns 删除表中模型的指定字段，不存在的列跳过
+func dropColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	return preserveSQLiteIndexes(tx, model, func() error {
+		migrator := tx.Migrator()
+		for _, field := range fields {
+			if !migrator.HasColumn(model, field) {
+				continue
+			}
+			if err := migrator.DropColumn(model, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// preserveSQLiteIndexes SQLite 修改、删除列时通过重建表实现，会丢失二级索引；
+// 执行 fn 前记录表上的索引定义，完成后补建仍然缺失且所涉列仍存在的索引
+func preserveSQLiteIndexes(tx *gorm.DB, model interface{}, fn func() error) error {
+	if tx.Dialector.Name() != "sqlite" {
+		return fn()
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	var indexes []struct {
+		Name string
+		SQL  string
+	}
+	if err := tx.Raw("SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL",
+		stmt.Table).Scan(&indexes).Error; err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		var exists int64
+		if err := tx.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?",
+			index.Name).Scan(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			continue
+		}
+		// 索引所涉列已被删除时放弃补建
+		if err := tx.Exec(index.SQL).Error; err != nil && !strings.Contains(err.Error(), "no such column") {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTableOptions 仅为 MySQL 附加建表选项
+func withTableOptions(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == "mysql" {
+		return tx.Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC")
+	}
+	return tx
+}
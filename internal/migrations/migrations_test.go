@@ -0,0 +1,225 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"weboffice/internal/models"
+	"weboffice/internal/storage"
+)
+
+// openTestDB 打开临时目录下的 SQLite 数据库，连接参数与 database 包一致
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "weboffice.db")
+	db, err := gorm.Open(sqlite.Open(path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func versions(migrations []Migration) []int {
+	var out []int
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+func TestAllOrdered(t *testing.T) {
+	all := All(nil)
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("第 %d 个迁移的版本号为 %d，迁移编号应连续", i+1, m.Version)
+		}
+		if m.Name == "" || m.Up == nil || m.Down == nil {
+			t.Errorf("迁移 %04d 定义不完整", m.Version)
+		}
+	}
+}
+
+func TestRunnerUpDown(t *testing.T) {
+	db := openTestDB(t)
+	runner := NewRunner(db, storage.NewMemoryStorage())
+	head := len(All(nil))
+
+	if err := runner.EnsureCurrent(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("空数据库 EnsureCurrent 返回 %v，期望 ErrSchemaOutdated", err)
+	}
+
+	// 按步数执行
+	done, err := runner.Up(2)
+	if err != nil {
+		t.Fatalf("Up(2): %v", err)
+	}
+	if got := versions(done); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("Up(2) 执行了 %v", got)
+	}
+
+	// 执行到最新版本
+	done, err = runner.Up(0)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(done) != head-2 {
+		t.Fatalf("Up 执行了 %d 个迁移，期望 %d", len(done), head-2)
+	}
+	if err := runner.EnsureCurrent(); err != nil {
+		t.Fatalf("迁移后 EnsureCurrent: %v", err)
+	}
+	if done, err := runner.Up(0); err != nil || len(done) != 0 {
+		t.Fatalf("重复执行 Up 返回 (%v, %v)", versions(done), err)
+	}
+	statuses, err := runner.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.AppliedAt == 0 {
+			t.Errorf("迁移 %04d_%s 未记录为已执行", s.Version, s.Name)
+		}
+	}
+	migrator := db.Migrator()
+	for _, table := range []string{"files", "file_versions", "blobs", "upload_sessions", "attachments"} {
+		if !migrator.HasTable(table) {
+			t.Errorf("缺少表 %s", table)
+		}
+	}
+	if !migrator.HasColumn(&models.FileVersion{}, "storage_key") {
+		t.Error("file_versions 缺少 storage_key 列")
+	}
+
+	// 回滚最近一个迁移后结构视为过旧
+	done, err = runner.Down(1)
+	if err != nil {
+		t.Fatalf("Down(1): %v", err)
+	}
+	if got := versions(done); len(got) != 1 || got[0] != head {
+		t.Fatalf("Down(1) 回滚了 %v", got)
+	}
+	err = runner.EnsureCurrent()
+	if !errors.Is(err, ErrSchemaOutdated) || !strings.Contains(err.Error(), done[0].Name) {
+		t.Fatalf("回滚后 EnsureCurrent 返回 %v，期望列出待执行的 %s", err, done[0].Name)
+	}
+
+	// 全部回滚后业务表被删除，再次迁移到最新版本
+	done, err = runner.Down(head)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(done) != head-1 || done[len(done)-1].Version != 1 {
+		t.Fatalf("Down 回滚了 %v", versions(done))
+	}
+	for _, table := range []string{"files", "file_versions", "blobs"} {
+		if migrator.HasTable(table) {
+			t.Errorf("全部回滚后仍存在表 %s", table)
+		}
+	}
+	if _, err := runner.Up(0); err != nil {
+		t.Fatalf("回滚后重新迁移失败: %v", err)
+	}
+	if err := runner.EnsureCurrent(); err != nil {
+		t.Fatalf("重新迁移后 EnsureCurrent: %v", err)
+	}
+}
+
+func TestEnsureCurrentUnknownVersion(t *testing.T) {
+	db := openTestDB(t)
+	runner := NewRunner(db, storage.NewMemoryStorage())
+	if _, err := runner.Up(0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := db.Create(&SchemaMigration{Version: 9999, Name: "future", AppliedAt: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err := runner.EnsureCurrent()
+	if err == nil || errors.Is(err, ErrSchemaOutdated) || !strings.Contains(err.Error(), "9999") {
+		t.Fatalf("EnsureCurrent 返回 %v，期望报告未知的迁移版本", err)
+	}
+}
+
+func TestUpRequiresStorageForDataMigrations(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := NewRunner(db, nil).Up(0); err == nil {
+		t.Fatal("未提供存储时数据迁移应返回错误")
+	}
+}
+
+func TestVersionContentMigratedToBlobs(t *testing.T) {
+	db := openTestDB(t)
+	store := storage.NewMemoryStorage()
+	runner := NewRunner(db, store)
+	if _, err := runner.Up(9); err != nil {
+		t.Fatalf("Up(9): %v", err)
+	}
+
+	// 0010 之前按版本号存储、未记录摘要的两个内容相同的版本与一个内容已丢失的版本
+	for version := 1; version <= 3; version++ {
+		if version < 3 {
+			if err := store.SaveFile("f1", version, "a.docx", strings.NewReader("same content")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.Create(&models.FileVersion{
+			ID: "f1", Version: version, Name: "a.docx", Size: 12, ModifierID: "u1",
+			VersionManifest: models.VersionManifest{StorageKey: fmt.Sprintf("f1/v%d", version)},
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := runner.Up(0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("same content"))
+	want := hex.EncodeToString(sum[:])
+	var rows []models.FileVersion
+	db.Where("id = ?", "f1").Order("version").Find(&rows)
+	for _, v := range rows[:2] {
+		if v.Sha256 != want || v.StorageKey != storage.ObjectStorageKey(want) {
+			t.Errorf("版本 %d 的 sha256=%q storage_key=%q", v.Version, v.Sha256, v.StorageKey)
+		}
+		if v.Sha1 == "" || v.Md5 == "" {
+			t.Errorf("版本 %d 未补全 sha1/md5", v.Version)
+		}
+	}
+	if missing := rows[2]; missing.Sha256 != "" || missing.StorageKey != "f1/v3" {
+		t.Errorf("内容缺失的版本不应修改: %+v", missing)
+	}
+
+	var blob models.Blob
+	if err := db.Where("sha256 = ?", want).First(&blob).Error; err != nil {
+		t.Fatalf("查询 Blob: %v", err)
+	}
+	if blob.RefCount != 2 || blob.Size != 12 {
+		t.Errorf("Blob ref_count=%d size=%d，期望 2、12", blob.RefCount, blob.Size)
+	}
+	rc, err := store.GetObject(want)
+	if err != nil {
+		t.Fatalf("读取 Blob 内容: %v", err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "same content" {
+		t.Errorf("Blob 内容为 %q", data)
+	}
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"errors"
+	"io"
+
+	"weboffice/internal/storage"
+)
+
+// contentStore 数据迁移读写存储后端使用的冻结接口。
+// 迁移只依赖这里的方法签名，storage.Storage 后续变更时只调整 liveStore，已发布的迁移无需修改
+type contentStore interface {
+	// getContent 按存储键读取版本内容
+	getContent(key string) (io.ReadCloser, error)
+	// putObject 以 key 写入附件或 Blob 对象
+	putObject(key string, src io.Reader) error
+	// blobKey 返回按摘要寻址的内容的存储键
+	blobKey(sum string) string
+}
+
+// errContentNotFound 内容在存储中不存在
+var errContentNotFound = errors.New("内容不存在")
+
+// liveStore 以当前的 storage.Storage 实现 contentStore
+type liveStore struct {
+	store storage.Storage
+}
+
+// newContentStore 包装存储后端，store 为 nil 时返回 nil，需要存储的迁移据此报错
+func newContentStore(store storage.Storage) contentStore {
+	if store == nil {
+		return nil
+	}
+	return liveStore{store: store}
+}
+
+func (s liveStore) getContent(key string) (io.ReadCloser, error) {
+	rc, err := s.store.GetFile(key)
+	return rc, mapNotFound(err)
+}
+
+func (s liveStore) putObject(key string, src io.Reader) error {
+	_, err := s.store.PutObject(key, src)
+	return err
+}
+
+func (s liveStore) blobKey(sum string) string {
+	return storage.ObjectStorageKey(sum)
+}
+
+func mapNotFound(err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return errContentNotFound
+	}
+	return err
+}
@@ -18,17 +18,43 @@ type File struct {
 	ModifyTime int64  `gorm:"not null" json:"modify_time"`
 	CreatorID  string `gorm:"size:48;not null" json:"creator_id"`
 	ModifierID string `gorm:"size:48;not null" json:"modifier_id"`
+	OwnerID    string `gorm:"size:48;index" json:"owner_id"` // 为空时以创建者为所有者
 }
 
 // FileVersion 文件版本历史
 type FileVersion struct {
-	ID string `gorm:"primaryKey;size:47;index:idx_file_versions"`
+	ID string `gorm:"primaryKey;type:char(36);index:idx_file_versions"`
 
 	Version    int    `gorm:"primaryKey;not null;index:idx_file_versions"`
 	Name       string `gorm:"size:240" json:"name"`
 	Size       int    `gorm:"not null" json:"size"`
 	CreateTime int64  `gorm:"not null" json:"create_time"`
 	ModifierID string `gorm:"size:48;not null" json:"modifier_id"`
+	Sha256     string `gorm:"size:64;index" json:"sha256,omitempty"` // 内容所在的 Blob，为空表示旧版本按版本号存储
+	Pinned     bool   `gorm:"not null;default:false" json:"pinned"`  // 固定的版本不会被保留策略清理
+	Label      string `gorm:"size:100" json:"label,omitempty"`       // 里程碑标签，如“已发送客户”“已签署”
+	Comment    string `gorm:"size:1000" json:"comment,omitempty"`    // 版本说明
+
+	VersionManifest `gorm:"embedded"`
+	ActiveContent   `gorm:"embedded"`
+}
+
+// VersionManifest 版本内容清单，与 Size、Sha256 一起随版本记录保存；
+// 读取内容只按 StorageKey 定位，不再按文件名或版本号推测存储路径
+type VersionManifest struct {
+	StorageKey   string `gorm:"size:300" json:"-"` // 内容在存储后端中的键，为空表示内容不可用
+	Sha1         string `gorm:"size:40" json:"sha1,omitempty"`
+	Md5          string `gorm:"size:32" json:"md5,omitempty"`
+	ContentType  string `gorm:"size:127" json:"content_type,omitempty"`
+	OriginalName string `gorm:"size:240" json:"original_name,omitempty"` // 内容上传时的文件名
+}
+
+// ActiveContent 上传时在文件内容中检测到的主动内容及处理方式
+type ActiveContent struct {
+	HasMacros           bool   `gorm:"not null;default:false" json:"has_macros"`
+	HasEmbeddedObjects  bool   `gorm:"not null;default:false" json:"has_embedded_objects"`
+	HasExternalLinks    bool   `gorm:"not null;default:false" json:"has_external_links"`
+	ActiveContentAction string `gorm:"size:16" json:"active_content_action,omitempty"` // 采取的处理：allow、strip，未检测到时为空
 }
 
 // User 用户信息
@@ -38,6 +64,110 @@ type User struct {
 	AvatarURL string `gorm:"size:200" json:"avatar_url,omitempty"`
 }
 
+// UserToken WebOffice 用户令牌，用于将回调中的令牌解析为用户
+type UserToken struct {
+	Token      string `gorm:"primaryKey;size:128" json:"-"`
+	UserID     string `gorm:"size:48;not null;index" json:"user_id"`
+	CreateTime int64  `gorm:"not null" json:"create_time"`
+	ExpireTime int64  `gorm:"not null;default:0" json:"expire_time"` // 0 表示永不过期
+}
+
+// 文件权限位
+const (
+	PermRead     = 1 << iota // 查看
+	PermUpdate               // 编辑、重命名、上传新版本
+	PermDownload             // 下载
+	PermHistory              // 查看历史版本
+	PermCopy                 // 复制
+	PermPrint                // 打印
+	PermManage               // 授权与撤销
+	PermComment              // 批注
+
+	PermAll = PermRead | PermUpdate | PermDownload | PermHistory | PermCopy | PermPrint | PermManage | PermComment
+)
+
+// 权限条目主体类型
+const (
+	SubjectUser  = "user"
+	SubjectGroup = "group"
+)
+
+// FileACL 文件访问控制条目，将权限集授予用户或用户组
+type FileACL struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	FileID      string `gorm:"size:47;not null;uniqueIndex:idx_file_acl_subject" json:"file_id"`
+	SubjectType string `gorm:"size:16;not null;uniqueIndex:idx_file_acl_subject" json:"subject_type"`
+	SubjectID   string `gorm:"size:48;not null;uniqueIndex:idx_file_acl_subject" json:"subject_id"`
+	Permissions int    `gorm:"not null" json:"permissions"`
+	GrantedBy   string `gorm:"size:48;not null" json:"granted_by"`
+	CreateTime  int64  `gorm:"not null" json:"create_time"`
+}
+
+// FileRetention 文件级版本保留策略，覆盖配置中的 retention.default
+type FileRetention struct {
+	FileID     string `gorm:"primaryKey;size:47" json:"file_id"`
+	KeepLast   int    `gorm:"not null;default:0" json:"keep_last"`
+	KeepDays   int    `gorm:"not null;default:0" json:"keep_days"`
+	KeepDaily  int    `gorm:"not null;default:0" json:"keep_daily"`
+	KeepWeekly int    `gorm:"not null;default:0" json:"keep_weekly"`
+	UpdatedBy  string `gorm:"size:48;not null" json:"updated_by"`
+	UpdateTime int64  `gorm:"not null" json:"update_time"`
+}
+
+// 分享链接权限范围
+const (
+	ShareScopeView    = "view"
+	ShareScopeComment = "comment"
+	ShareScopeEdit    = "edit"
+)
+
+// ShareLink 文件分享链接，供 users 表以外的访客按限定权限访问文件
+type ShareLink struct {
+	ID           string `gorm:"primaryKey;type:char(36)" json:"id"`
+	FileID       string `gorm:"size:47;not null;index" json:"file_id"`
+	Token        string `gorm:"size:64;not null;uniqueIndex" json:"token"` // 分享链接中的公开令牌
+	Scope        string `gorm:"size:16;not null" json:"scope"`
+	PasswordHash string `gorm:"size:100" json:"-"`
+	MaxUses      int    `gorm:"not null;default:0" json:"max_uses"` // 0 表示不限次数
+	UseCount     int    `gorm:"not null;default:0" json:"use_count"`
+	ExpireTime   int64  `gorm:"not null;default:0" json:"expire_time"` // 0 表示永不过期
+	CreatorID    string `gorm:"size:48;not null" json:"creator_id"`
+	CreateTime   int64  `gorm:"not null" json:"create_time"`
+	RevokeTime   int64  `gorm:"not null;default:0" json:"revoke_time"` // 非 0 表示已撤销
+}
+
+// Active 判断分享链接当前是否可用
+func (l *ShareLink) Active(now int64) bool {
+	return l.RevokeTime == 0 && (l.ExpireTime == 0 || now < l.ExpireTime)
+}
+
+// Permissions 返回分享范围对应的权限位
+func (l *ShareLink) Permissions() int {
+	switch l.Scope {
+	case ShareScopeView:
+		return PermRead
+	case ShareScopeComment:
+		return PermRead | PermComment
+	case ShareScopeEdit:
+		return PermRead | PermComment | PermUpdate
+	}
+	return 0
+}
+
+// GroupMember 用户组成员关系
+type GroupMember struct {
+	GroupID string `gorm:"primaryKey;size:48" json:"group_id"`
+	UserID  string `gorm:"primaryKey;size:48;index" json:"user_id"`
+}
+
+// Owner 返回文件所有者ID
+func (f *File) Owner() string {
+	if f.OwnerID != "" {
+		return f.OwnerID
+	}
+	return f.CreatorID
+}
+
 // Watermark 水印配置
 type Watermark struct {
 	FileID     string `gorm:"primaryKey;size:47" json:"file_id"`
@@ -47,11 +177,61 @@ type Watermark struct {
 	Vertical   int    `gorm:"not null" json:"vertical"`
 }
 
-// Attachment 附件存储
+// Attachment 附件元信息，内容保存在存储后端
 type Attachment struct {
-	Key       string `gorm:"primaryKey;size:100" json:"key"`
-	Data      []byte `gorm:"type:longblob" json:"-"`
+	Key         string `gorm:"primaryKey;size:100" json:"key"`
+	Size        int64  `gorm:"not null;default:0" json:"size"`
+	Md5         string `gorm:"size:32" json:"md5"`
+	Sha1        string `gorm:"size:40" json:"sha1"`
+	ContentType string `gorm:"size:100" json:"content_type"`
+	Sha256      string `gorm:"size:64;index" json:"sha256,omitempty"` // 内容所在的 Blob
+	StorageKey  string `gorm:"size:100" json:"-"`                     // 存储后端中的对象键
+	CreatedAt   int64  `gorm:"not null" json:"created_at"`
+}
+
+// Blob 按 SHA-256 寻址的内容，内容相同的版本与附件共享同一份存储；
+// 对象键为摘要的十六进制形式，RefCount 为引用它的版本与附件数量
+type Blob struct {
+	Sha256    string `gorm:"primaryKey;size:64" json:"sha256"`
+	Size      int64  `gorm:"not null" json:"size"`
+	RefCount  int64  `gorm:"not null;default:0;index" json:"ref_count"`
 	CreatedAt int64  `gorm:"not null" json:"created_at"`
+	UpdatedAt int64  `gorm:"not null" json:"updated_at"` // 引用计数最近一次变化的时间
+}
+
+// 上传会话状态
+const (
+	UploadStatusPrepared    = "prepared"    // 已调用上传准备
+	UploadStatusAddressed   = "addressed"   // 已签发上传地址
+	UploadStatusUploading   = "uploading"   // 上传端点正在接收内容
+	UploadStatusUploaded    = "uploaded"    // 内容已暂存并通过校验
+	UploadStatusCompleted   = "completed"   // 已提交为新版本
+	UploadStatusFailed      = "failed"      // 校验失败或上传被放弃
+	UploadStatusQuarantined = "quarantined" // 内容已隔离待审核，不生成版本
+)
+
+// UploadSession 上传会话，串联 prepare → address → complete 三个阶段
+type UploadSession struct {
+	ID           string `gorm:"primaryKey;type:char(36)" json:"id"` // 同时作为一次性上传凭证
+	FileID       string `gorm:"size:47;not null;index" json:"file_id"`
+	Status       string `gorm:"size:16;not null;index" json:"status"`
+	Name         string `gorm:"size:240" json:"name"`
+	Size         int64  `gorm:"not null;default:0" json:"size"` // 声明的文件大小
+	DeclaredSha1 string `gorm:"size:40" json:"declared_sha1,omitempty"`
+	DeclaredMd5  string `gorm:"size:32" json:"declared_md5,omitempty"`
+	ReceivedSize int64  `gorm:"not null;default:0" json:"received_size"` // 实际接收的字节数
+	Sha1         string `gorm:"size:40" json:"sha1,omitempty"`           // 实际内容摘要
+	Md5          string `gorm:"size:32" json:"md5,omitempty"`
+	Sha256       string `gorm:"size:64" json:"sha256,omitempty"` // 暂存内容（移除主动内容后）的摘要
+	StoredSha1   string `gorm:"size:40" json:"stored_sha1,omitempty"`
+	StoredMd5    string `gorm:"size:32" json:"stored_md5,omitempty"`
+	StoredSize   int64  `gorm:"not null;default:0" json:"stored_size"` // 暂存内容大小，主动内容被移除时小于 ReceivedSize
+	Reason       string `gorm:"size:255" json:"reason,omitempty"`      // 隔离原因
+	CreateTime   int64  `gorm:"not null" json:"create_time"`
+	UpdateTime   int64  `gorm:"not null" json:"update_time"`
+	ExpireTime   int64  `gorm:"not null;index" json:"expire_time"`
+
+	ActiveContent `gorm:"embedded"`
 }
 
 // Refresh 从数据库重新加载最新数据
@@ -0,0 +1,195 @@
+// Package retention 按保留策略清理文件的历史版本，在后台执行以免拖慢上传提交
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weboffice/internal/config"
+	"weboffice/internal/database"
+	"weboffice/internal/models"
+)
+
+// Expired 返回按策略 p 应被清理的版本。当前版本与固定的版本始终保留；其余版本满足任一规则即保留：
+// 最近 KeepLast 个、KeepDays 天内创建的、此前 KeepDaily 天内每天最后一个、再往前 KeepWeekly 周内每周最后一个。
+// 策略未设置任何规则时不清理
+func Expired(p config.RetentionPolicy, versions []models.FileVersion, current int, now time.Time) []models.FileVersion {
+	if p.IsZero() {
+		return nil
+	}
+
+	sorted := make([]models.FileVersion, len(versions))
+	copy(sorted, versions)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version > sorted[j].Version })
+
+	recentCutoff := now.AddDate(0, 0, -p.KeepDays)
+	dailyCutoff := recentCutoff.AddDate(0, 0, -p.KeepDaily)
+	weeklyCutoff := dailyCutoff.AddDate(0, 0, -7*p.KeepWeekly)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+
+	var expired []models.FileVersion
+	for i, v := range sorted {
+		created := time.Unix(v.CreateTime, 0).In(now.Location())
+		switch {
+		case v.Version == current || v.Pinned:
+		case i < p.KeepLast:
+		case p.KeepDays > 0 && !created.Before(recentCutoff):
+		case p.KeepDaily > 0 && !created.Before(dailyCutoff) && created.Before(recentCutoff):
+			// 版本号降序遍历，每天首个出现的即当天最后一个版本
+			day := created.Format("2006-01-02")
+			if days[day] {
+				expired = append(expired, v)
+			}
+			days[day] = true
+		case p.KeepWeekly > 0 && !created.Before(weeklyCutoff) && created.Before(dailyCutoff):
+			year, week := created.ISOWeek()
+			key := fmt.Sprintf("%d-%d", year, week)
+			if weeks[key] {
+				expired = append(expired, v)
+			}
+			weeks[key] = true
+		default:
+			expired = append(expired, v)
+		}
+	}
+	return expired
+}
+
+// PolicyFor 返回文件生效的保留策略：文件单独设置的策略优先，否则为 defaults
+func PolicyFor(db *gorm.DB, fileID string, defaults config.RetentionPolicy) (config.RetentionPolicy, bool, error) {
+	var record models.FileRetention
+	err := db.Where("file_id = ?", fileID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaults, false, nil
+	}
+	if err != nil {
+		return defaults, false, fmt.Errorf("查询文件保留策略失败: %w", err)
+	}
+	return config.RetentionPolicy{
+		KeepLast:   record.KeepLast,
+		KeepDays:   record.KeepDays,
+		KeepDaily:  record.KeepDaily,
+		KeepWeekly: record.KeepWeekly,
+	}, true, nil
+}
+
+// Report 一次清理的结果
+type Report struct {
+	Files    int // 检查的文件数
+	Versions int // 清理的版本数
+	Errors   int // 清理失败的文件数，下次执行时重试
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("检查文件 %d 个，清理版本 %d 个，失败 %d 个", r.Files, r.Versions, r.Errors)
+}
+
+// Worker 按保留策略清理历史版本。只删除版本记录并释放内容引用，
+// 内容本身由垃圾回收在宽限期后删除
+type Worker struct {
+	db       *gorm.DB
+	defaults config.RetentionPolicy
+	mu       sync.Mutex // 定时任务不重叠执行
+}
+
+func NewWorker(db *gorm.DB, defaults config.RetentionPolicy) *Worker {
+	return &Worker{db: db, defaults: defaults}
+}
+
+// Run 对全部文件执行一次清理，单个文件失败只计入 Report.Errors
+func (w *Worker) Run() (*Report, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var fileIDs []string
+	if err := w.db.Model(&models.File{}).Pluck("id", &fileIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询文件列表失败: %w", err)
+	}
+
+	report := &Report{}
+	for _, fileID := range fileIDs {
+		report.Files++
+		pruned, err := w.ApplyFile(fileID)
+		if err != nil {
+			log.Printf("[retention] 清理文件 %s 的历史版本失败: %v", fileID, err)
+			report.Errors++
+			continue
+		}
+		report.Versions += pruned
+	}
+	return report, nil
+}
+
+// ApplyFile 在主文件行级锁保护下按文件生效的策略清理其历史版本，返回清理的版本数
+func (w *Worker) ApplyFile(fileID string) (int, error) {
+	pruned := 0
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		// 与提交新版本使用同一把锁，避免清理与版本号递增交错
+		var file models.File
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "version").
+			Where("id = ?", fileID).
+			First(&file).Error; err != nil {
+			return fmt.Errorf("查询文件失败: %w", err)
+		}
+
+		policy, _, err := PolicyFor(tx, fileID, w.defaults)
+		if err != nil {
+			return err
+		}
+		if policy.IsZero() {
+			return nil
+		}
+
+		var versions []models.FileVersion
+		if err := tx.Select("id", "version", "create_time", "sha256", "pinned").
+			Where("id = ?", fileID).
+			Find(&versions).Error; err != nil {
+			return fmt.Errorf("查询版本失败: %w", err)
+		}
+
+		for _, v := range Expired(policy, versions, file.Version, time.Now()) {
+			result := tx.Where("id = ? AND version = ? AND pinned = ?", fileID, v.Version, false).
+				Delete(&models.FileVersion{})
+			if result.Error != nil {
+				return fmt.Errorf("删除版本 %d 失败: %w", v.Version, result.Error)
+			}
+			// 版本可能已被固定或删除，未删除时不能释放其内容引用
+			if result.RowsAffected != 1 {
+				continue
+			}
+			if v.Sha256 != "" {
+				if err := database.ReleaseBlob(tx, v.Sha256); err != nil {
+					return err
+				}
+			}
+			pruned++
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+// Start 启动后台任务，按 interval 定期清理历史版本
+func (w *Worker) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := w.Run()
+			if err != nil {
+				log.Printf("历史版本清理失败: %v", err)
+				continue
+			}
+			log.Printf("历史版本清理完成: %s", report)
+		}
+	}()
+}
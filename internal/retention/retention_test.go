@@ -0,0 +1,181 @@
+package retention
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"weboffice/internal/config"
+	"weboffice/internal/models"
+)
+
+func TestExpired(t *testing.T) {
+	// 按 UTC+8 计算日期与周，UTC 下部分版本会落在另一天
+	zone := time.FixedZone("CST", 8*3600)
+	at := func(value string) int64 {
+		ts, err := time.ParseInLocation("2006-01-02 15:04", value, zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts.Unix()
+	}
+	now := time.Date(2024, 6, 12, 12, 0, 0, 0, zone) // 星期三
+	type version struct {
+		number  int
+		created string
+		pinned  bool
+	}
+
+	tests := []struct {
+		name     string
+		policy   config.RetentionPolicy
+		now      time.Time
+		current  int
+		versions []version
+		want     []int
+	}{
+		{
+			name:     "no rules keeps everything",
+			policy:   config.RetentionPolicy{},
+			current:  3,
+			versions: []version{{1, "2020-01-01 00:00", false}, {2, "2020-01-02 00:00", false}, {3, "2020-01-03 00:00", false}},
+		},
+		{
+			name:     "keep last counts the current version",
+			policy:   config.RetentionPolicy{KeepLast: 2},
+			current:  5,
+			versions: []version{{1, "2024-01-01 00:00", false}, {2, "2024-01-02 00:00", false}, {3, "2024-01-03 00:00", false}, {4, "2024-01-04 00:00", false}, {5, "2024-01-05 00:00", false}},
+			want:     []int{3, 2, 1},
+		},
+		{
+			name:    "keep days includes the cutoff instant",
+			policy:  config.RetentionPolicy{KeepDays: 7},
+			current: 5,
+			versions: []version{
+				{5, "2024-06-12 12:00", false},
+				{4, "2024-06-10 09:00", false},
+				{3, "2024-06-05 12:00", false},
+				{2, "2024-06-05 11:59", false},
+				{1, "2024-05-01 00:00", false},
+			},
+			want: []int{2, 1},
+		},
+		{
+			name:    "daily keeps the last version of each day before the recent window",
+			policy:  config.RetentionPolicy{KeepDays: 1, KeepDaily: 3},
+			current: 9,
+			versions: []version{
+				{9, "2024-06-12 09:00", false},
+				{8, "2024-06-11 13:00", false}, // 1 天内
+				{7, "2024-06-11 10:00", false}, // 06-11 最后一个
+				{6, "2024-06-11 08:00", false},
+				{5, "2024-06-10 20:00", false},
+				{4, "2024-06-10 09:00", false},
+				{3, "2024-06-09 15:00", false},
+				{2, "2024-06-08 13:00", false},
+				{1, "2024-06-08 11:00", false}, // 早于按天保留的范围
+			},
+			want: []int{6, 4, 1},
+		},
+		{
+			name:    "daily uses the day boundary of now's location",
+			policy:  config.RetentionPolicy{KeepDaily: 3},
+			current: 4,
+			versions: []version{
+				{4, "2024-06-12 12:00", false},
+				{3, "2024-06-10 00:30", false}, // UTC 下为 06-09
+				{2, "2024-06-09 23:30", false},
+				{1, "2024-06-09 23:00", false},
+			},
+			want: []int{1},
+		},
+		{
+			name:    "weekly keeps the last version of each ISO week",
+			policy:  config.RetentionPolicy{KeepWeekly: 2},
+			current: 9,
+			versions: []version{
+				{9, "2024-06-12 12:00", false},
+				{8, "2024-06-11 10:00", false}, // 第 24 周最后一个
+				{7, "2024-06-10 08:00", false}, // 星期一
+				{6, "2024-06-09 23:00", false}, // 星期日，第 23 周最后一个
+				{5, "2024-06-03 00:30", false},
+				{4, "2024-06-02 23:59", false}, // 第 22 周最后一个
+				{3, "2024-05-30 10:00", false},
+				{2, "2024-05-29 11:00", false}, // 早于按周保留的范围
+			},
+			want: []int{7, 5, 3, 2},
+		},
+		{
+			name:    "weekly uses the ISO year across the new year",
+			policy:  config.RetentionPolicy{KeepWeekly: 2},
+			now:     time.Date(2025, 1, 8, 12, 0, 0, 0, zone),
+			current: 4,
+			versions: []version{
+				{4, "2025-01-08 12:00", false},
+				{3, "2025-01-01 10:00", false}, // 2025 年第 1 周
+				{2, "2024-12-30 10:00", false}, // 同属 2025 年第 1 周
+				{1, "2024-12-29 10:00", false}, // 2024 年第 52 周
+			},
+			want: []int{2},
+		},
+		{
+			name:    "rules apply after recent and daily windows",
+			policy:  config.RetentionPolicy{KeepLast: 1, KeepDays: 1, KeepDaily: 2, KeepWeekly: 1},
+			current: 7,
+			versions: []version{
+				{7, "2024-06-12 11:00", false},
+				{6, "2024-06-11 12:30", false}, // 1 天内
+				{5, "2024-06-10 18:00", false}, // 按天保留
+				{4, "2024-06-10 17:00", false},
+				{3, "2024-06-08 18:00", false}, // 按周保留（第 23 周）
+				{2, "2024-06-07 18:00", false},
+				{1, "2024-06-01 18:00", false}, // 早于全部规则
+			},
+			want: []int{4, 2, 1},
+		},
+		{
+			name:    "pinned and current versions outside every rule are kept",
+			policy:  config.RetentionPolicy{KeepDays: 7},
+			current: 5,
+			versions: []version{
+				{5, "2023-01-05 00:00", false},
+				{4, "2023-01-04 00:00", true},
+				{3, "2023-01-03 00:00", false},
+				{2, "2023-01-02 00:00", true},
+				{1, "2023-01-01 00:00", false},
+			},
+			want: []int{3, 1},
+		},
+		{
+			name:    "restored current version older than newer ones",
+			policy:  config.RetentionPolicy{KeepLast: 1},
+			current: 2,
+			versions: []version{
+				{1, "2024-01-01 00:00", false},
+				{2, "2024-01-02 00:00", false},
+				{3, "2024-01-03 00:00", false},
+			},
+			want: []int{1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var versions []models.FileVersion
+			for _, v := range tc.versions {
+				versions = append(versions, models.FileVersion{ID: "f1", Version: v.number, CreateTime: at(v.created), Pinned: v.pinned})
+			}
+			ref := tc.now
+			if ref.IsZero() {
+				ref = now
+			}
+
+			var got []int
+			for _, v := range Expired(tc.policy, versions, tc.current, ref) {
+				got = append(got, v.Version)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("清理版本 %v，期望 %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -5,13 +5,21 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"weboffice/internal/config"
 	"weboffice/internal/handlers"
+	"weboffice/internal/middleware"
 )
 
 // RegisterRoutes 注册所有路由
-func RegisterRoutes(r *gin.Engine) {
+// WebOffice 回调接口均需通过签名校验；下载与上传端点由签名链接/一次性凭证自行鉴权
+func RegisterRoutes(r *gin.Engine, cfg *config.AppConfig, resolver middleware.IdentityResolver) {
+	callbackAuth := []gin.HandlerFunc{
+		middleware.CallbackAuth(cfg.CallbackAuth),
+		middleware.Authenticate(resolver),
+	}
+
 	// 文件相关路由
-	fileGroup := r.Group("/v3/3rd/files")
+	fileGroup := r.Group("/v3/3rd/files", callbackAuth...)
 	{
 		fileGroup.GET("/:file_id", handlers.GetFile)
 		fileGroup.GET("/:file_id/download", handlers.GetDownloadURL)
@@ -22,6 +30,11 @@ func RegisterRoutes(r *gin.Engine) {
 		fileGroup.GET("/:file_id/versions", handlers.ListVersions)
 		fileGroup.GET("/:file_id/versions/:version", handlers.GetVersion)
 		fileGroup.GET("/:file_id/versions/:version/download", handlers.GetDownloadURL)
+		fileGroup.POST("/:file_id/versions/:version/restore", handlers.RestoreVersion)
+		fileGroup.PUT("/:file_id/versions/:version/label", handlers.LabelVersion)
+		fileGroup.PUT("/:file_id/versions/:version/pin", handlers.PinVersion)
+		fileGroup.DELETE("/:file_id/versions/:version/pin", handlers.UnpinVersion)
+		fileGroup.GET("/:file_id/diff", handlers.DiffVersions)
 
 		// 上传相关路由
 		fileGroup.GET("/:file_id/upload/prepare", handlers.PrepareUpload)
@@ -37,19 +50,45 @@ func RegisterRoutes(r *gin.Engine) {
 	}
 
 	// 用户相关路由
-	userGroup := r.Group("/v3/3rd/users")
+	userGroup := r.Group("/v3/3rd/users", callbackAuth...)
 	{
 		userGroup.GET("", handlers.GetUsers)
 	}
 
 	// 对象存储路由
-	objectGroup := r.Group("/v3/3rd/object")
+	objectGroup := r.Group("/v3/3rd/object", callbackAuth...)
 	{
 		objectGroup.PUT("/:key", handlers.UploadObject)
 		objectGroup.GET("/:key/url", handlers.GetObjectURL)
 		objectGroup.POST("/copy", handlers.CopyObject)
 	}
-	// 添加实际文件下载路由
-	fileGroup.GET("/:file_id/content", handlers.DownloadFile)
+	// 管理接口：文件权限授予与撤销、分享链接、版本保留策略
+	adminGroup := r.Group("/v3/admin", callbackAuth...)
+	{
+		adminGroup.GET("/files/:file_id/acl", handlers.ListFileACL)
+		adminGroup.PUT("/files/:file_id/acl", handlers.GrantFileACL)
+		adminGroup.DELETE("/files/:file_id/acl/:subject_type/:subject_id", handlers.RevokeFileACL)
+
+		adminGroup.GET("/files/:file_id/shares", handlers.ListShareLinks)
+		adminGroup.POST("/files/:file_id/shares", handlers.CreateShareLink)
+		adminGroup.DELETE("/files/:file_id/shares/:share_id", handlers.RevokeShareLink)
+
+		adminGroup.GET("/files/:file_id/retention", handlers.GetFileRetention)
+		adminGroup.PUT("/files/:file_id/retention", handlers.SetFileRetention)
+		adminGroup.DELETE("/files/:file_id/retention", handlers.DeleteFileRetention)
+	}
+
+	// 分享链接换取 WebOffice 令牌（分享令牌与密码鉴权）
+	r.POST("/v3/share/:token", handlers.OpenShareLink)
+
+	// 添加实际文件下载路由（签名链接鉴权）
+	r.GET("/v3/3rd/files/:file_id/content", handlers.DownloadFile)
+
+	// 附件内容下载路由（签名链接鉴权）
+	r.GET("/v3/3rd/object/:key/content", handlers.DownloadObject)
+	r.HEAD("/v3/3rd/object/:key/content", handlers.DownloadObject)
+
+	// 上传地址对应的内容上传端点（一次性凭证）
+	r.PUT("/v3/3rd/upload/:token", handlers.UploadContent)
 
 }
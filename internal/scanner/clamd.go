@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamd INSTREAM 分块大小，需小于 clamd 的 StreamMaxLength
+const clamdChunkSize = 64 << 10
+
+// Clamd 通过 clamd 协议（INSTREAM）扫描内容，支持 TCP 与 unix 套接字
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+var (
+	_ Scanner = (*Clamd)(nil)
+	_ Pinger  = (*Clamd)(nil)
+)
+
+// NewClamd 创建 clamd 客户端，network 为 tcp 或 unix，timeout 为单次读写的超时
+func NewClamd(network, address string, timeout time.Duration) *Clamd {
+	if network == "" {
+		network = "tcp"
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Clamd{network: network, address: address, timeout: timeout}
+}
+
+// Scan 以 zINSTREAM 命令分块发送内容：每块为 4 字节大端长度 + 数据，以长度 0 结束
+func (s *Clamd) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("连接 clamd 失败: %w", err)
+	}
+	defer conn.Close()
+
+	// 取消上下文时中断阻塞的读写
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("发送扫描命令失败: %w", err)
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			conn.SetDeadline(time.Now().Add(s.timeout))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd 超出大小限制时会提前回复并关闭连接，优先返回其回复
+				if reply, replyErr := readClamdReply(conn); replyErr == nil {
+					return parseClamdReply(reply)
+				}
+				return nil, fmt.Errorf("发送扫描内容失败: %w", err)
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("读取待扫描内容失败: %w", readErr)
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("发送扫描结束标记失败: %w", err)
+	}
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseClamdReply(reply)
+}
+
+// Ping 检查 clamd 是否可用
+func (s *Clamd) Ping(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return fmt.Errorf("连接 clamd 失败: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd 响应异常: %s", reply)
+	}
+	return nil
+}
+
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil && len(reply) == 0 {
+		return "", fmt.Errorf("读取 clamd 响应失败: %w", err)
+	}
+	reply = bytes.TrimRight(reply, "\x00\n")
+	if len(reply) == 0 {
+		return "", errors.New("clamd 未返回结果")
+	}
+	return string(reply), nil
+}
+
+// parseClamdReply 解析 "stream: OK"、"stream: <名称> FOUND"、"<原因> ERROR" 形式的回复
+func parseClamdReply(reply string) (*Result, error) {
+	body := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case body == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(body, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(body, " FOUND")}, nil
+	case strings.HasSuffix(body, " ERROR"):
+		return nil, fmt.Errorf("clamd 扫描失败: %s", strings.TrimSuffix(body, " ERROR"))
+	}
+	return nil, fmt.Errorf("无法识别的 clamd 响应: %s", reply)
+}
@@ -0,0 +1,148 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd 本地 clamd 替身：接收 zINSTREAM 分块并按 reply 回复，
+// reply 为空字符串时读完内容后直接关闭连接
+type fakeClamd struct {
+	listener net.Listener
+	reply    func(content []byte) string
+	received chan []byte
+}
+
+func newFakeClamd(t *testing.T, reply func(content []byte) string) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	f := &fakeClamd{listener: listener, reply: reply, received: make(chan []byte, 1)}
+	t.Cleanup(func() { listener.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeClamd) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var content bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, r, int64(size)); err != nil {
+				return
+			}
+		}
+		select {
+		case f.received <- content.Bytes():
+		default:
+		}
+		if reply := f.reply(content.Bytes()); reply != "" {
+			conn.Write([]byte(reply + "\x00"))
+		}
+	}
+}
+
+func (f *fakeClamd) client() *Clamd {
+	return NewClamd("tcp", f.listener.Addr().String(), 2*time.Second)
+}
+
+func TestClamdScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		infected  bool
+		signature string
+		wantErr   string
+	}{
+		{name: "clean", reply: "stream: OK"},
+		{name: "infected", reply: "stream: Eicar-Signature FOUND", infected: true, signature: "Eicar-Signature"},
+		{name: "error", reply: "INSTREAM size limit exceeded. ERROR", wantErr: "clamd 扫描失败"},
+		{name: "early close", reply: "", wantErr: "clamd 未返回结果"},
+		{name: "unknown", reply: "stream: ???", wantErr: "无法识别的 clamd 响应"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakeClamd(t, func([]byte) string { return tc.reply })
+			result, err := fake.client().Scan(context.Background(), strings.NewReader("content"))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Scan 返回错误 %v，期望包含 %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Infected != tc.infected || result.Signature != tc.signature {
+				t.Errorf("Scan 返回 %+v", result)
+			}
+		})
+	}
+}
+
+func TestClamdScanSendsContentInChunks(t *testing.T) {
+	fake := newFakeClamd(t, func([]byte) string { return "stream: OK" })
+
+	// 超过单个分块大小，需要多次发送
+	content := bytes.Repeat([]byte("weboffice"), clamdChunkSize/4)
+	if _, err := fake.client().Scan(context.Background(), bytes.NewReader(content)); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got := <-fake.received; !bytes.Equal(got, content) {
+		t.Errorf("clamd 收到 %d 字节，期望 %d 字节", len(got), len(content))
+	}
+}
+
+func TestClamdScanUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	if _, err := NewClamd("tcp", address, time.Second).Scan(context.Background(), strings.NewReader("x")); err == nil {
+		t.Fatal("clamd 不可用时 Scan 应返回错误")
+	}
+}
+
+func TestClamdPing(t *testing.T) {
+	fake := newFakeClamd(t, func([]byte) string { return "stream: OK" })
+	if err := fake.client().Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Package scanner 上传内容病毒扫描
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"weboffice/internal/config"
+)
+
+// Result 扫描结果
+type Result struct {
+	Infected  bool
+	Signature string // 命中的病毒特征名称
+}
+
+// Scanner 内容扫描接口，返回错误表示扫描未能完成（服务不可用、超时等）
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// Pinger 可选接口，支持连通性检查的扫描器在启动时检查服务是否可用
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// 扫描驱动名称
+const (
+	DriverNone  = "none"
+	DriverClamd = "clamd"
+)
+
+// New 根据配置创建扫描器，未启用时返回 nil
+func New(cfg *config.ScannerConfig) (Scanner, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	switch cfg.Driver {
+	case "", DriverNone:
+		return nil, nil
+	case DriverClamd:
+		return NewClamd(cfg.Network, cfg.Address, cfg.Timeout), nil
+	default:
+		return nil, fmt.Errorf("不支持的扫描驱动: %s", cfg.Driver)
+	}
+}
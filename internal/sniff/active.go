@@ -0,0 +1,149 @@
+package sniff
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ActiveContent 文件中检测到的主动内容
+type ActiveContent struct {
+	Macros          bool     // VBA 工程
+	EmbeddedObjects bool     // OLE 嵌入对象、ActiveX 控件
+	ExternalLinks   bool     // 指向外部的模板、数据源、对象链接（不含普通超链接）
+	Details         []string // 命中的部件或条目，便于审计
+}
+
+// Any 是否检测到任意主动内容
+func (a *ActiveContent) Any() bool {
+	return a.Macros || a.EmbeddedObjects || a.ExternalLinks
+}
+
+func (a *ActiveContent) add(kind, detail string) {
+	a.Details = append(a.Details, kind+":"+detail)
+}
+
+const (
+	relTypeHyperlink  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	relTypeVBAProject = "http://schemas.microsoft.com/office/2006/relationships/vbaProject"
+)
+
+// InspectActiveContent 检测宏、嵌入对象与外部链接。
+// OOXML 检查包内部件与关系；OLE2 只检查目录结构，无法识别存放在文档流内部的对象（如 ppt 的 VBA）与外部链接；
+// RTF 检查 \object 与 INCLUDE* 域；其余格式不包含此类内容
+func InspectActiveContent(r io.ReaderAt, size int64, kind Kind) (*ActiveContent, error) {
+	switch kind {
+	case KindOOXML:
+		return inspectOOXML(r, size)
+	case KindOLE2:
+		doc, err := ReadCFB(r, size)
+		if err != nil {
+			return nil, fmt.Errorf("解析复合文档失败: %w", err)
+		}
+		return inspectCFB(doc), nil
+	case KindRTF:
+		return inspectRTF(r, size)
+	}
+	return &ActiveContent{}, nil
+}
+
+// isMacroPart 判断 OOXML 部件是否属于 VBA 工程
+func isMacroPart(name string) bool {
+	base := strings.ToLower(path.Base(name))
+	return base == "vbaproject.bin" || base == "vbadata.xml" ||
+		base == "vbaproject.bin.rels" || base == "vbadata.xml.rels"
+}
+
+func inspectOOXML(r io.ReaderAt, size int64) (*ActiveContent, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("读取 zip 结构失败: %w", err)
+	}
+
+	found := &ActiveContent{}
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		switch {
+		case isMacroPart(name):
+			found.Macros = true
+			found.add("macro", f.Name)
+		case strings.Contains(name, "/embeddings/"), strings.Contains(name, "/activex/"):
+			found.EmbeddedObjects = true
+			found.add("embedded", f.Name)
+		case strings.Contains(name, "/externallinks/") && !strings.Contains(name, "/_rels/"):
+			found.ExternalLinks = true
+			found.add("external", f.Name)
+		}
+
+		if !strings.HasSuffix(name, ".rels") {
+			continue
+		}
+		var rels relationships
+		if err := decodePart(f, &rels); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", f.Name, err)
+		}
+		for _, rel := range rels.Relationships {
+			if strings.EqualFold(rel.TargetMode, "External") && rel.Type != relTypeHyperlink {
+				found.ExternalLinks = true
+				found.add("external", rel.Target)
+			}
+		}
+	}
+	return found, nil
+}
+
+func inspectCFB(doc *CFB) *ActiveContent {
+	found := &ActiveContent{}
+	for _, e := range doc.Entries {
+		name := strings.ToLower(e.Name)
+		topLevel := !strings.Contains(e.Path, "/")
+		switch {
+		case topLevel && (name == "macros" || name == "_vba_project_cur"),
+			e.Type == CFBStorage && name == "vba":
+			found.Macros = true
+			found.add("macro", e.Path)
+		case topLevel && name == "objectpool" && e.child != cfbNoStream,
+			topLevel && e.Type == CFBStorage && strings.HasPrefix(name, "mbd"),
+			name == "\x01ole10native":
+			found.EmbeddedObjects = true
+			found.add("embedded", e.Path)
+		}
+	}
+	return found
+}
+
+// RTF 中表示嵌入对象与外部链接的控制字与域指令
+var (
+	rtfObjectTokens   = [][]byte{[]byte(`\object`), []byte(`\objdata`)}
+	rtfExternalTokens = [][]byte{[]byte(`\objautlink`), []byte(`\objlink`), []byte("INCLUDEPICTURE"), []byte("INCLUDETEXT")}
+)
+
+func inspectRTF(r io.ReaderAt, size int64) (*ActiveContent, error) {
+	found := &ActiveContent{}
+	const chunk = 64 << 10
+	const overlap = 32
+	buf := make([]byte, chunk+overlap)
+	for off := int64(0); off < size; off += chunk {
+		n, err := r.ReadAt(buf, off)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("读取文件内容失败: %w", err)
+		}
+		data := buf[:n]
+		for _, token := range rtfObjectTokens {
+			if !found.EmbeddedObjects && bytes.Contains(data, token) {
+				found.EmbeddedObjects = true
+				found.add("embedded", string(token))
+			}
+		}
+		for _, token := range rtfExternalTokens {
+			if !found.ExternalLinks && bytes.Contains(data, token) {
+				found.ExternalLinks = true
+				found.add("external", string(token))
+			}
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,104 @@
+package sniff
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestInspectActiveContent(t *testing.T) {
+	const (
+		relsNS       = "http://schemas.openxmlformats.org/package/2006/relationships"
+		templateRel  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/attachedTemplate"
+		externalRels = `<Relationships xmlns="` + relsNS + `"><Relationship Id="rId1" Type="` + templateRel +
+			`" Target="file:///C:/evil.dotm" TargetMode="External"/></Relationships>`
+		hyperlinkRels = `<Relationships xmlns="` + relsNS + `"><Relationship Id="rId1" Type="` + relTypeHyperlink +
+			`" Target="https://example.com" TargetMode="External"/></Relationships>`
+		externalLinkRels = `<Relationships xmlns="` + relsNS + `"/>`
+	)
+	word := func(extra ...zipFile) []byte { return buildOOXML(t, "word/document.xml", docmMain, extra...) }
+	// RTF 中跨越读取块边界的控制字也应被识别
+	straddling := append(append([]byte(`{\rtf1 `), bytes.Repeat([]byte("x"), 64<<10-10)...), `{\object\objemb}}`...)
+
+	tests := []struct {
+		name                       string
+		kind                       Kind
+		data                       []byte
+		macros, embedded, external bool
+	}{
+		{"clean ooxml", KindOOXML, word(), false, false, false},
+		{"vba project", KindOOXML, word(zipFile{"word/vbaProject.bin", "vba"}), true, false, false},
+		{"vba data", KindOOXML, word(zipFile{"word/vbaData.xml", "<wne:vbaSuppData/>"}), true, false, false},
+		{"ole embedding", KindOOXML, word(zipFile{"word/embeddings/oleObject1.bin", "ole"}), false, true, false},
+		{"activex control", KindOOXML, word(zipFile{"word/activeX/activeX1.xml", "<ax/>"}), false, true, false},
+		{"external workbook link", KindOOXML, buildOOXML(t, "xl/workbook.xml", xlsxMain,
+			zipFile{"xl/externalLinks/externalLink1.xml", "<externalLink/>"},
+			zipFile{"xl/externalLinks/_rels/externalLink1.xml.rels", externalLinkRels},
+		), false, false, true},
+		{"external template relationship", KindOOXML, word(zipFile{"word/_rels/settings.xml.rels", externalRels}), false, false, true},
+		{"hyperlink is not active content", KindOOXML, word(zipFile{"word/_rels/document.xml.rels", hyperlinkRels}), false, false, false},
+
+		{"clean ole2", KindOLE2, buildCFB(stream("WordDocument"), stream("1Table")), false, false, false},
+		{"word macros storage", KindOLE2, buildCFB(stream("WordDocument"), storage("Macros", storage("VBA", stream("dir")))), true, false, false},
+		{"excel vba project", KindOLE2, buildCFB(stream("Workbook"), storage("_VBA_PROJECT_CUR", storage("VBA"))), true, false, false},
+		{"empty object pool", KindOLE2, buildCFB(stream("WordDocument"), storage("ObjectPool")), false, false, false},
+		{"object pool entry", KindOLE2, buildCFB(stream("WordDocument"), storage("ObjectPool", storage("_1234", stream("CompObj")))), false, true, false},
+		{"excel embedded storage", KindOLE2, buildCFB(stream("Workbook"), storage("MBD0001A2B3", stream("CompObj"))), false, true, false},
+		{"ole10 native stream", KindOLE2, buildCFB(stream("PowerPoint Document"), storage("x", stream("\x01Ole10Native"))), false, true, false},
+
+		{"plain rtf", KindRTF, []byte(`{\rtf1\ansi 正文\par}`), false, false, false},
+		{"rtf embedded object", KindRTF, []byte(`{\rtf1{\object\objemb{\*\objdata 0105}}}`), false, true, false},
+		{"rtf include field", KindRTF, []byte(`{\rtf1{\field{\*\fldinst INCLUDEPICTURE "http://x/a.png"}}}`), false, false, true},
+		{"rtf linked object", KindRTF, []byte(`{\rtf1{\object\objautlink{\*\objdata 01}}}`), false, true, true},
+		{"rtf token across chunks", KindRTF, straddling, false, true, false},
+
+		{"text never has active content", KindText, []byte(`\object INCLUDETEXT`), false, false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := InspectActiveContent(bytes.NewReader(tc.data), int64(len(tc.data)), tc.kind)
+			if err != nil {
+				t.Fatalf("InspectActiveContent: %v", err)
+			}
+			if found.Macros != tc.macros || found.EmbeddedObjects != tc.embedded || found.ExternalLinks != tc.external {
+				t.Errorf("检测结果 宏=%v 嵌入=%v 外链=%v，期望 %v %v %v（%q）",
+					found.Macros, found.EmbeddedObjects, found.ExternalLinks, tc.macros, tc.embedded, tc.external, found.Details)
+			}
+			if found.Any() != (tc.macros || tc.embedded || tc.external) {
+				t.Errorf("Any = %v", found.Any())
+			}
+		})
+	}
+}
+
+func TestInspectActiveContentDetails(t *testing.T) {
+	data := buildCFB(stream("WordDocument"), storage("Macros", storage("VBA")), storage("ObjectPool", storage("_1")))
+	found, err := InspectActiveContent(bytes.NewReader(data), int64(len(data)), KindOLE2)
+	if err != nil {
+		t.Fatalf("InspectActiveContent: %v", err)
+	}
+	want := []string{"macro:Macros", "macro:Macros/VBA", "embedded:ObjectPool"}
+	if !reflect.DeepEqual(found.Details, want) {
+		t.Errorf("Details = %q，期望 %q", found.Details, want)
+	}
+}
+
+func TestInspectActiveContentInvalid(t *testing.T) {
+	cfb := buildCFB(stream("WordDocument"))
+	tests := []struct {
+		name string
+		kind Kind
+		data []byte
+	}{
+		{"corrupt zip", KindOOXML, []byte("PK\x03\x04 not really a zip")},
+		{"malformed relationships", KindOOXML, buildOOXML(t, "word/document.xml", docxMain, zipFile{"word/_rels/document.xml.rels", "<Relationships>"})},
+		{"truncated ole2", KindOLE2, cfb[:700]},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := InspectActiveContent(bytes.NewReader(tc.data), int64(len(tc.data)), tc.kind); err == nil {
+				t.Fatal("期望返回错误")
+			}
+		})
+	}
+}
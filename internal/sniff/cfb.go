@@ -0,0 +1,226 @@
+package sniff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// OLE2 复合文件二进制格式（MS-CFB）的最小解析：只读取目录，不读取流内容
+
+var cfbMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+const (
+	cfbHeaderSize  = 512
+	cfbDirEntry    = 128
+	cfbEndOfChain  = 0xFFFFFFFE
+	cfbNoStream    = 0xFFFFFFFF
+	cfbMaxSector   = 0xFFFFFFFA
+	cfbHeaderDIFAT = 109
+	cfbMaxEntries  = 1 << 16 // 目录条目数上限，正常文档远小于该值
+)
+
+// 目录条目类型
+const (
+	CFBStorage = 1
+	CFBStream  = 2
+	CFBRoot    = 5
+)
+
+// CFBEntry 复合文档目录条目
+type CFBEntry struct {
+	Name string
+	Path string // 以 / 分隔的完整路径，如 Macros/VBA/dir
+	Type int
+	Size int64
+
+	left, right, child uint32
+}
+
+// CFB 复合文档目录
+type CFB struct {
+	Entries []CFBEntry // 不含根条目
+}
+
+// RootNames 返回根存储下直接包含的条目名称
+func (d *CFB) RootNames() []string {
+	var names []string
+	for _, e := range d.Entries {
+		if !strings.Contains(e.Path, "/") {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// Has 判断是否存在指定路径的条目（不区分大小写）
+func (d *CFB) Has(path string) bool {
+	for _, e := range d.Entries {
+		if strings.EqualFold(e.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadCFB 解析复合文档头、FAT 与目录
+func ReadCFB(r io.ReaderAt, size int64) (*CFB, error) {
+	header := make([]byte, cfbHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+	le := binary.LittleEndian
+	if string(header[:8]) != string(cfbMagic) || le.Uint16(header[0x1C:]) != 0xFFFE {
+		return nil, errors.New("文件头签名无效")
+	}
+	shift := le.Uint16(header[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, fmt.Errorf("不支持的扇区大小 2^%d", shift)
+	}
+	sectorSize := int64(1) << shift
+	// 文件中可能存在的扇区数，用于约束所有链表遍历
+	sectorCount := uint32((size + sectorSize - 1) / sectorSize)
+
+	readSector := func(id uint32) ([]byte, error) {
+		if id > cfbMaxSector || id >= sectorCount {
+			return nil, fmt.Errorf("扇区号越界: %d", id)
+		}
+		buf := make([]byte, sectorSize)
+		n, err := r.ReadAt(buf, (int64(id)+1)*sectorSize)
+		if err != nil && !(err == io.EOF && n > 0) {
+			return nil, fmt.Errorf("读取扇区 %d 失败: %w", id, err)
+		}
+		return buf, nil
+	}
+
+	// 收集 FAT 扇区号：文件头中的 109 项 + DIFAT 扇区链
+	numFAT := le.Uint32(header[0x2C:])
+	if numFAT > sectorCount {
+		return nil, errors.New("FAT 扇区数无效")
+	}
+	var fatSectors []uint32
+	for i := 0; i < cfbHeaderDIFAT && uint32(len(fatSectors)) < numFAT; i++ {
+		fatSectors = append(fatSectors, le.Uint32(header[0x4C+4*i:]))
+	}
+	next := le.Uint32(header[0x44:])
+	perDIFAT := int(sectorSize/4) - 1
+	for steps := uint32(0); uint32(len(fatSectors)) < numFAT; steps++ {
+		if next > cfbMaxSector || steps >= sectorCount {
+			return nil, errors.New("DIFAT 链无效")
+		}
+		buf, err := readSector(next)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < perDIFAT && uint32(len(fatSectors)) < numFAT; i++ {
+			fatSectors = append(fatSectors, le.Uint32(buf[4*i:]))
+		}
+		next = le.Uint32(buf[4*perDIFAT:])
+	}
+
+	fat := make([]uint32, 0, int64(len(fatSectors))*sectorSize/4)
+	for _, id := range fatSectors {
+		buf, err := readSector(id)
+		if err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < sectorSize; i += 4 {
+			fat = append(fat, le.Uint32(buf[i:]))
+		}
+	}
+
+	// 沿 FAT 链读取目录扇区
+	var raw []byte
+	visited := make(map[uint32]bool)
+	for id := le.Uint32(header[0x30:]); id != cfbEndOfChain; {
+		if visited[id] || int(id) >= len(fat) {
+			return nil, errors.New("目录扇区链无效")
+		}
+		visited[id] = true
+		buf, err := readSector(id)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, buf...)
+		id = fat[id]
+	}
+
+	if len(raw)/cfbDirEntry > cfbMaxEntries {
+		return nil, errors.New("目录条目过多")
+	}
+	entries := make([]CFBEntry, 0, len(raw)/cfbDirEntry)
+	for off := 0; off+cfbDirEntry <= len(raw); off += cfbDirEntry {
+		entries = append(entries, parseDirEntry(raw[off:off+cfbDirEntry], shift))
+	}
+	if len(entries) == 0 || entries[0].Type != CFBRoot {
+		return nil, errors.New("缺少根目录条目")
+	}
+
+	doc := &CFB{}
+	seen := make(map[uint32]bool)
+	var walk func(id uint32, parent string) error
+	walk = func(id uint32, parent string) error {
+		if id == cfbNoStream {
+			return nil
+		}
+		if int(id) >= len(entries) || seen[id] {
+			return errors.New("目录树结构无效")
+		}
+		seen[id] = true
+		e := entries[id]
+		if err := walk(e.left, parent); err != nil {
+			return err
+		}
+		e.Path = e.Name
+		if parent != "" {
+			e.Path = parent + "/" + e.Name
+		}
+		if e.Type == CFBStorage || e.Type == CFBStream {
+			doc.Entries = append(doc.Entries, e)
+		}
+		if e.Type == CFBStorage {
+			if err := walk(e.child, e.Path); err != nil {
+				return err
+			}
+		}
+		return walk(e.right, parent)
+	}
+	seen[0] = true
+	if err := walk(entries[0].child, ""); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func parseDirEntry(b []byte, shift uint16) CFBEntry {
+	le := binary.LittleEndian
+	nameLen := int(le.Uint16(b[0x40:]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+	units := make([]uint16, 0, nameLen/2)
+	for i := 0; i+1 < nameLen; i += 2 {
+		u := le.Uint16(b[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+
+	size := int64(le.Uint64(b[0x78:]))
+	if shift == 9 {
+		// 版本 3 文件只使用低 32 位
+		size = int64(le.Uint32(b[0x78:]))
+	}
+	return CFBEntry{
+		Name:  string(utf16.Decode(units)),
+		Type:  int(b[0x42]),
+		Size:  size,
+		left:  le.Uint32(b[0x44:]),
+		right: le.Uint32(b[0x48:]),
+		child: le.Uint32(b[0x4C:]),
+	}
+}
@@ -0,0 +1,176 @@
+package sniff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// cfbNode 测试用复合文档中的一个存储或流
+type cfbNode struct {
+	name     string
+	typ      int
+	children []cfbNode
+}
+
+func stream(name string) cfbNode { return cfbNode{name: name, typ: CFBStream} }
+
+func storage(name string, children ...cfbNode) cfbNode {
+	return cfbNode{name: name, typ: CFBStorage, children: children}
+}
+
+// buildCFB 生成 512 字节扇区的版本 3 复合文档：扇区 0 为 FAT，其后为目录扇区。
+// 同一存储下的条目以 right 指针串联，流不含内容
+func buildCFB(nodes ...cfbNode) []byte {
+	le := binary.LittleEndian
+	const sector = 512
+
+	type flat struct {
+		name               string
+		typ                int
+		left, right, child uint32
+	}
+	entries := []flat{{name: "Root Entry", typ: CFBRoot, left: cfbNoStream, right: cfbNoStream, child: cfbNoStream}}
+	var add func(siblings []cfbNode) uint32
+	add = func(siblings []cfbNode) uint32 {
+		first := uint32(cfbNoStream)
+		prev := -1
+		for _, n := range siblings {
+			id := len(entries)
+			entries = append(entries, flat{name: n.name, typ: n.typ, left: cfbNoStream, right: cfbNoStream, child: cfbNoStream})
+			if prev < 0 {
+				first = uint32(id)
+			} else {
+				entries[prev].right = uint32(id)
+			}
+			entries[id].child = add(n.children)
+			prev = id
+		}
+		return first
+	}
+	entries[0].child = add(nodes)
+
+	dirSectors := (len(entries)*cfbDirEntry + sector - 1) / sector
+	out := make([]byte, sector*(2+dirSectors))
+
+	header := out[:sector]
+	copy(header, cfbMagic)
+	le.PutUint16(header[0x18:], 0x3E)
+	le.PutUint16(header[0x1A:], 3)
+	le.PutUint16(header[0x1C:], 0xFFFE)
+	le.PutUint16(header[0x1E:], 9)
+	le.PutUint16(header[0x20:], 6)
+	le.PutUint32(header[0x2C:], 1)
+	le.PutUint32(header[0x30:], 1)
+	le.PutUint32(header[0x38:], 0x1000)
+	le.PutUint32(header[0x3C:], cfbEndOfChain)
+	le.PutUint32(header[0x44:], cfbEndOfChain)
+	for i := 0; i < cfbHeaderDIFAT; i++ {
+		le.PutUint32(header[0x4C+4*i:], cfbNoStream)
+	}
+	le.PutUint32(header[0x4C:], 0)
+
+	fat := out[sector : 2*sector]
+	for i := 0; i < sector/4; i++ {
+		le.PutUint32(fat[4*i:], cfbNoStream)
+	}
+	le.PutUint32(fat, 0xFFFFFFFD)
+	for i := 1; i <= dirSectors; i++ {
+		next := uint32(i + 1)
+		if i == dirSectors {
+			next = cfbEndOfChain
+		}
+		le.PutUint32(fat[4*i:], next)
+	}
+
+	dir := out[2*sector:]
+	for i, e := range entries {
+		b := dir[i*cfbDirEntry : (i+1)*cfbDirEntry]
+		units := utf16.Encode([]rune(e.name))
+		for j, u := range units {
+			le.PutUint16(b[2*j:], u)
+		}
+		le.PutUint16(b[0x40:], uint16(2*(len(units)+1)))
+		b[0x42] = byte(e.typ)
+		b[0x43] = 1
+		le.PutUint32(b[0x44:], e.left)
+		le.PutUint32(b[0x48:], e.right)
+		le.PutUint32(b[0x4C:], e.child)
+		le.PutUint32(b[0x74:], cfbEndOfChain)
+	}
+	return out
+}
+
+func TestReadCFB(t *testing.T) {
+	data := buildCFB(
+		stream("WordDocument"),
+		stream("1Table"),
+		storage("Macros", storage("VBA", stream("dir"), stream("ThisDocument"))),
+		storage("ObjectPool", storage("_1234", stream("\x01Ole10Native"))),
+	)
+	doc, err := ReadCFB(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ReadCFB: %v", err)
+	}
+
+	if got, want := doc.RootNames(), []string{"WordDocument", "1Table", "Macros", "ObjectPool"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RootNames = %q，期望 %q", got, want)
+	}
+	var paths []string
+	for _, e := range doc.Entries {
+		paths = append(paths, e.Path)
+	}
+	want := []string{
+		"WordDocument", "1Table", "Macros", "Macros/VBA", "Macros/VBA/dir", "Macros/VBA/ThisDocument",
+		"ObjectPool", "ObjectPool/_1234", "ObjectPool/_1234/\x01Ole10Native",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("条目路径 %q，期望 %q", paths, want)
+	}
+	if !doc.Has("macros/vba/DIR") {
+		t.Error("Has 应不区分大小写")
+	}
+	if doc.Has("Macros/dir") {
+		t.Error("Has 不应匹配不存在的路径")
+	}
+}
+
+func TestReadCFBInvalid(t *testing.T) {
+	valid := buildCFB(stream("WordDocument"), stream("1Table"))
+	le := binary.LittleEndian
+	corrupt := func(fn func(b []byte)) []byte {
+		b := append([]byte(nil), valid...)
+		fn(b)
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"truncated header", valid[:100], "读取文件头失败"},
+		{"truncated fat", valid[:512], "读取扇区 0 失败"},
+		{"truncated directory", valid[:1024], "读取扇区 1 失败"},
+		{"fat sector out of range", corrupt(func(b []byte) { le.PutUint32(b[0x4C:], 50) }), "扇区号越界"},
+		{"bad signature", corrupt(func(b []byte) { b[0] = 0 }), "文件头签名无效"},
+		{"bad byte order", corrupt(func(b []byte) { le.PutUint16(b[0x1C:], 0xFEFF) }), "文件头签名无效"},
+		{"bad sector shift", corrupt(func(b []byte) { le.PutUint16(b[0x1E:], 10) }), "不支持的扇区大小"},
+		{"too many fat sectors", corrupt(func(b []byte) { le.PutUint32(b[0x2C:], 1000) }), "FAT 扇区数无效"},
+		{"directory chain loop", corrupt(func(b []byte) { le.PutUint32(b[512+4:], 1) }), "目录扇区链无效"},
+		{"missing root", corrupt(func(b []byte) { b[1024+0x42] = CFBStream }), "缺少根目录条目"},
+		{"tree cycle", corrupt(func(b []byte) { le.PutUint32(b[1024+2*cfbDirEntry+0x48:], 1) }), "目录树结构无效"},
+		{"child out of range", corrupt(func(b []byte) { le.PutUint32(b[1024+0x4C:], 99) }), "目录树结构无效"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadCFB(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("ReadCFB 返回错误 %v，期望包含 %q", err, tc.wantErr)
+			}
+		})
+	}
+}
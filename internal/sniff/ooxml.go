@@ -0,0 +1,88 @@
+package sniff
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var zipMagic = []byte("PK\x03\x04")
+
+// OOXML 包内部件解析时读取的最大字节数，防止压缩炸弹
+const maxPartSize = 4 << 20
+
+const officeDocumentRel = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+
+type contentTypes struct {
+	Overrides []struct {
+		PartName    string `xml:"PartName,attr"`
+		ContentType string `xml:"ContentType,attr"`
+	} `xml:"Override"`
+}
+
+type relationships struct {
+	Relationships []Relationship `xml:"Relationship"`
+}
+
+// Relationship OOXML 部件关系
+type Relationship struct {
+	ID         string `xml:"Id,attr"`
+	Type       string `xml:"Type,attr"`
+	Target     string `xml:"Target,attr"`
+	TargetMode string `xml:"TargetMode,attr"`
+}
+
+// detectZip 区分 OOXML 包与普通 zip，OOXML 需包含 [Content_Types].xml 并能定位主文档部件
+func detectZip(r io.ReaderAt, size int64) (*Result, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: zip 结构无效: %v", ErrContentMismatch, err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+	ctFile, ok := files["[Content_Types].xml"]
+	if !ok {
+		return &Result{Kind: KindZip}, nil
+	}
+
+	var types contentTypes
+	if err := decodePart(ctFile, &types); err != nil {
+		return nil, fmt.Errorf("%w: [Content_Types].xml 无效: %v", ErrContentMismatch, err)
+	}
+	overrides := make(map[string]string, len(types.Overrides))
+	for _, o := range types.Overrides {
+		overrides[strings.TrimPrefix(o.PartName, "/")] = o.ContentType
+	}
+
+	result := &Result{Kind: KindOOXML}
+	if relsFile, ok := files["_rels/.rels"]; ok {
+		var rels relationships
+		if err := decodePart(relsFile, &rels); err != nil {
+			return nil, fmt.Errorf("%w: _rels/.rels 无效: %v", ErrContentMismatch, err)
+		}
+		for _, rel := range rels.Relationships {
+			if rel.Type == officeDocumentRel {
+				target := strings.TrimPrefix(path.Clean("/"+rel.Target), "/")
+				result.MainContentType = overrides[target]
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// decodePart 解析 OOXML 包内的 XML 部件
+func decodePart(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(v)
+}
@@ -0,0 +1,215 @@
+// Package sniff 根据文件内容（魔数与容器结构）识别 Office 文档的真实格式，
+// 用于拒绝内容与扩展名不一致的上传文件
+package sniff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Kind 文件内容的容器/编码格式
+type Kind string
+
+const (
+	KindEmpty   Kind = "empty"
+	KindOOXML   Kind = "ooxml" // Office Open XML（zip 容器）
+	KindZip     Kind = "zip"   // 非 OOXML 的 zip 容器
+	KindOLE2    Kind = "ole2"  // OLE2 复合文档（doc/xls/ppt 及 WPS 格式）
+	KindRTF     Kind = "rtf"
+	KindHTML    Kind = "html"
+	KindMHTML   Kind = "mhtml"
+	KindXML     Kind = "xml"
+	KindText    Kind = "text"
+	KindUnknown Kind = "unknown"
+)
+
+// ErrContentMismatch 文件内容与扩展名不一致
+var ErrContentMismatch = errors.New("文件内容与扩展名不一致")
+
+// 文本类格式判断时读取的前缀长度
+const headSize = 8 << 10
+
+// Result 内容识别结果
+type Result struct {
+	Kind Kind
+	// OOXML 主文档部件的内容类型，如 application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml
+	MainContentType string
+	// OLE2 根存储下的条目名称，如 WordDocument、Workbook
+	Streams []string
+}
+
+// Detect 识别文件内容格式
+func Detect(r io.ReaderAt, size int64) (*Result, error) {
+	if size == 0 {
+		return &Result{Kind: KindEmpty}, nil
+	}
+
+	head := make([]byte, headSize)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return detectZip(r, size)
+	case bytes.HasPrefix(head, cfbMagic):
+		doc, err := ReadCFB(r, size)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 复合文档结构无效: %v", ErrContentMismatch, err)
+		}
+		return &Result{Kind: KindOLE2, Streams: doc.RootNames()}, nil
+	}
+	return &Result{Kind: detectText(head)}, nil
+}
+
+// expectation 扩展名对应的内容要求
+type expectation struct {
+	kinds        []Kind
+	contentTypes []string // OOXML 主文档内容类型
+	streams      []string // OLE2 根存储下必须存在其一的流
+	allowEmpty   bool
+}
+
+const (
+	wordStream  = "WordDocument"
+	excelStream = "Workbook"
+	excel95     = "Book"
+	pptStream   = "PowerPoint Document"
+)
+
+var expectations = map[string]expectation{
+	// OOXML 文字文档
+	"docx": ooxml("application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"),
+	"dotx": ooxml("application/vnd.openxmlformats-officedocument.wordprocessingml.template.main+xml"),
+	"docm": ooxml("application/vnd.ms-word.document.macroEnabled.main+xml"),
+	"dotm": ooxml("application/vnd.ms-word.template.macroEnabledTemplate.main+xml"),
+	// OOXML 表格文档
+	"xlsx": ooxml("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"),
+	"xltx": ooxml("application/vnd.openxmlformats-officedocument.spreadsheetml.template.main+xml"),
+	"xlsm": ooxml("application/vnd.ms-excel.sheet.macroEnabled.main+xml"),
+	"xltm": ooxml("application/vnd.ms-excel.template.macroEnabled.main+xml"),
+	// OOXML 演示文档
+	"pptx": ooxml("application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml"),
+	"potx": ooxml("application/vnd.openxmlformats-officedocument.presentationml.template.main+xml"),
+	"ppsx": ooxml("application/vnd.openxmlformats-officedocument.presentationml.slideshow.main+xml"),
+	"pptm": ooxml("application/vnd.ms-powerpoint.presentation.macroEnabled.main+xml"),
+	"potm": ooxml("application/vnd.ms-powerpoint.template.macroEnabled.main+xml"),
+	"ppsm": ooxml("application/vnd.ms-powerpoint.slideshow.macroEnabled.main+xml"),
+
+	// OLE2 二进制格式（WPS 格式与对应的 MS Office 格式结构兼容）
+	"doc": ole2(wordStream),
+	"dot": ole2(wordStream),
+	"wps": ole2(wordStream),
+	"wpt": ole2(wordStream),
+	"xls": ole2(excelStream, excel95),
+	"xlt": ole2(excelStream, excel95),
+	"et":  ole2(excelStream, excel95),
+	"ett": ole2(excelStream, excel95),
+	"ppt": ole2(pptStream),
+	"pps": ole2(pptStream),
+	"pot": ole2(pptStream),
+	"dps": ole2(pptStream),
+	"dpt": ole2(pptStream),
+
+	// 文本类格式
+	"rtf":   {kinds: []Kind{KindRTF}},
+	"html":  {kinds: []Kind{KindHTML}},
+	"htm":   {kinds: []Kind{KindHTML}},
+	"mht":   {kinds: []Kind{KindMHTML}},
+	"mhtml": {kinds: []Kind{KindMHTML}},
+	"xml":   {kinds: []Kind{KindXML}},
+	"txt":   {kinds: []Kind{KindText, KindXML, KindHTML}, allowEmpty: true},
+	"csv":   {kinds: []Kind{KindText}, allowEmpty: true},
+
+	// UOF 为 XML 格式，UOF 2.0 及以后为 zip 容器
+	"uof":  {kinds: []Kind{KindXML, KindZip}},
+	"uot3": {kinds: []Kind{KindXML, KindZip}},
+}
+
+func ooxml(contentTypes ...string) expectation {
+	return expectation{kinds: []Kind{KindOOXML}, contentTypes: contentTypes}
+}
+
+func ole2(streams ...string) expectation {
+	return expectation{kinds: []Kind{KindOLE2}, streams: streams}
+}
+
+// Known 判断是否为扩展名定义了内容校验规则
+func Known(ext string) bool {
+	_, ok := expectations[strings.ToLower(ext)]
+	return ok
+}
+
+// CheckExtension 识别内容格式并校验其与扩展名（不带点）是否一致；
+// 未定义校验规则的扩展名只做识别不做校验
+func CheckExtension(ext string, r io.ReaderAt, size int64) (*Result, error) {
+	ext = strings.ToLower(ext)
+	result, err := Detect(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	want, ok := expectations[ext]
+	if !ok {
+		return result, nil
+	}
+	if result.Kind == KindEmpty {
+		if want.allowEmpty {
+			return result, nil
+		}
+		return result, fmt.Errorf("%w: .%s 文件内容为空", ErrContentMismatch, ext)
+	}
+	if !containsKind(want.kinds, result.Kind) {
+		return result, fmt.Errorf("%w: .%s 文件的实际格式为 %s", ErrContentMismatch, ext, result.Kind)
+	}
+
+	if len(want.contentTypes) > 0 && !containsString(want.contentTypes, result.MainContentType) {
+		return result, fmt.Errorf("%w: .%s 文件的主文档类型为 %q", ErrContentMismatch, ext, result.MainContentType)
+	}
+	if len(want.streams) > 0 {
+		found := false
+		for _, name := range want.streams {
+			if containsFold(result.Streams, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return result, fmt.Errorf("%w: .%s 复合文档缺少 %s 流", ErrContentMismatch, ext, want.streams[0])
+		}
+	}
+	return result, nil
+}
+
+func containsKind(kinds []Kind, k Kind) bool {
+	for _, kind := range kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold 复合文档条目名称不区分大小写
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
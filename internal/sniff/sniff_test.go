@@ -0,0 +1,192 @@
+package sniff
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const (
+	docxMain = "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"
+	docmMain = "application/vnd.ms-word.document.macroEnabled.main+xml"
+	xlsxMain = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"
+)
+
+// zipFile zip 包中的一个部件，按顺序写入
+type zipFile struct {
+	name, content string
+}
+
+func buildZip(t *testing.T, files ...zipFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildOOXML 生成主文档为 mainPart 的最小 OOXML 包，extra 为额外部件
+func buildOOXML(t *testing.T, mainPart, mainContentType string, extra ...zipFile) []byte {
+	t.Helper()
+	files := []zipFile{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/` + mainPart + `" ContentType="` + mainContentType + `"/></Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + officeDocumentRel + `" Target="` + mainPart + `"/></Relationships>`},
+		{mainPart, `<document/>`},
+	}
+	return buildZip(t, append(files, extra...)...)
+}
+
+func utf16Text(s string, order binary.ByteOrder, bom []byte) []byte {
+	out := append([]byte(nil), bom...)
+	for _, u := range utf16.Encode([]rune(s)) {
+		b := make([]byte, 2)
+		order.PutUint16(b, u)
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Kind
+	}{
+		{"empty", nil, KindEmpty},
+		{"plain text", []byte("名称,数量\r\n苹果,3\r\n"), KindText},
+		{"utf-8 bom", append(append([]byte(nil), utf8BOM...), "<?xml version=\"1.0\"?><a/>"...), KindXML},
+		{"utf-16le bom text", utf16Text("名称\t数量\r\n", binary.LittleEndian, utf16LEBOM), KindText},
+		{"utf-16be bom html", utf16Text("<html><body>正文</body></html>", binary.BigEndian, utf16BEBOM), KindHTML},
+		{"utf-16le bom xml", utf16Text("<?xml version=\"1.0\"?><a/>", binary.LittleEndian, utf16LEBOM), KindXML},
+		{"utf-16 without bom", utf16Text("abc", binary.LittleEndian, nil), KindUnknown},
+		{"rtf", []byte(`{\rtf1\ansi 正文}`), KindRTF},
+		{"html fragment", []byte("  <!-- x --><body>x</body>"), KindHTML},
+		{"mhtml", []byte("MIME-Version: 1.0\r\nContent-Type: multipart/related; boundary=x\r\n"), KindMHTML},
+		{"binary", []byte{0x01, 0x02, 0x00, 0x03}, KindUnknown},
+		{"plain zip", buildZip(t, zipFile{"a.txt", "x"}), KindZip},
+		{"ooxml", buildOOXML(t, "word/document.xml", docxMain), KindOOXML},
+		{"ole2", buildCFB(stream("WordDocument")), KindOLE2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Detect(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if result.Kind != tc.want {
+				t.Errorf("Kind = %s，期望 %s", result.Kind, tc.want)
+			}
+		})
+	}
+}
+
+func TestDetectContainerDetails(t *testing.T) {
+	data := buildOOXML(t, "word/document.xml", docxMain)
+	result, err := Detect(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if result.MainContentType != docxMain {
+		t.Errorf("MainContentType = %q", result.MainContentType)
+	}
+
+	data = buildCFB(stream("WordDocument"), stream("1Table"), storage("ObjectPool"))
+	result, err = Detect(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if strings.Join(result.Streams, ",") != "WordDocument,1Table,ObjectPool" {
+		t.Errorf("Streams = %q", result.Streams)
+	}
+}
+
+func TestCheckExtension(t *testing.T) {
+	docx := buildOOXML(t, "word/document.xml", docxMain)
+	docm := buildOOXML(t, "word/document.xml", docmMain)
+	xlsx := buildOOXML(t, "xl/workbook.xml", xlsxMain)
+	doc := buildCFB(stream("WordDocument"), stream("1Table"))
+	xls95 := buildCFB(stream("Book"))
+	lowerCase := buildCFB(stream("workbook"))
+	truncated := doc[:512]
+	noMain := buildZip(t,
+		zipFile{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`},
+		zipFile{"word/document.xml", "<document/>"},
+	)
+	badContentTypes := buildZip(t, zipFile{"[Content_Types].xml", "<Types>"})
+
+	tests := []struct {
+		name    string
+		ext     string
+		data    []byte
+		wantErr string // 为空表示校验通过
+	}{
+		{"docx", "docx", docx, ""},
+		{"upper-case extension", "DOCX", docx, ""},
+		{"macro document renamed to docx", "docx", docm, "主文档类型为"},
+		{"spreadsheet renamed to docx", "docx", xlsx, "主文档类型为"},
+		{"xlsx", "xlsx", xlsx, ""},
+		{"ooxml without main part", "docx", noMain, "主文档类型为"},
+		{"invalid content types", "docx", badContentTypes, "[Content_Types].xml 无效"},
+		{"ole2 renamed to docx", "docx", doc, "实际格式为 ole2"},
+		{"doc", "doc", doc, ""},
+		{"wps", "wps", doc, ""},
+		{"doc missing WordDocument", "doc", xls95, "缺少 WordDocument 流"},
+		{"xls missing Workbook", "xls", doc, "缺少 Workbook 流"},
+		{"excel 95 workbook", "xls", xls95, ""},
+		{"stream names ignore case", "et", lowerCase, ""},
+		{"truncated ole2", "doc", truncated, "复合文档结构无效"},
+		{"docx renamed to doc", "doc", docx, "实际格式为 ooxml"},
+		{"empty txt", "txt", nil, ""},
+		{"empty csv", "csv", nil, ""},
+		{"empty docx", "docx", nil, "文件内容为空"},
+		{"empty doc", "doc", nil, "文件内容为空"},
+		{"utf-16 csv", "csv", utf16Text("a,b\r\n", binary.LittleEndian, utf16LEBOM), ""},
+		{"html renamed to txt", "txt", []byte("<html></html>"), ""},
+		{"html renamed to csv", "csv", []byte("<html></html>"), "实际格式为 html"},
+		{"binary renamed to txt", "txt", []byte{0x00, 0x01}, "实际格式为 unknown"},
+		{"unknown extension only detects", "pdf", []byte{0x00, 0x01}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := CheckExtension(tc.ext, bytes.NewReader(tc.data), int64(len(tc.data)))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckExtension(%s) 返回错误: %v", tc.ext, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrContentMismatch) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("CheckExtension(%s) 返回错误 %v，期望 ErrContentMismatch 且包含 %q", tc.ext, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestKnown(t *testing.T) {
+	for ext, want := range map[string]bool{"docx": true, "XLSM": true, "et": true, "csv": true, "pdf": false, "": false} {
+		if got := Known(ext); got != want {
+			t.Errorf("Known(%q) = %v，期望 %v", ext, got, want)
+		}
+	}
+}
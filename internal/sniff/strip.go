@@ -0,0 +1,147 @@
+package sniff
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// StripMacros 从 OOXML 包中移除 VBA 工程部件及指向它们的关系，结果写入 w，返回被移除的部件。
+// 主文档内容类型保持不变，不含 VBA 工程的启用宏格式文档仍可正常打开
+func StripMacros(r io.ReaderAt, size int64, w io.Writer) ([]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("读取 zip 结构失败: %w", err)
+	}
+
+	removed := make(map[string]bool)
+	var removedList []string
+	for _, f := range zr.File {
+		if isMacroPart(f.Name) {
+			removed[strings.TrimPrefix(f.Name, "/")] = true
+			removedList = append(removedList, f.Name)
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(f.Name, "/")
+		if removed[name] {
+			continue
+		}
+
+		var content []byte
+		switch {
+		case name == "[Content_Types].xml":
+			content, err = stripContentTypes(f, removed)
+		case strings.HasSuffix(strings.ToLower(name), ".rels"):
+			content, err = stripRelationships(f, name, removed)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("处理 %s 失败: %w", f.Name, err)
+		}
+
+		if content == nil {
+			// 其余部件原样复制，不重新压缩
+			if err := zw.Copy(f); err != nil {
+				return nil, fmt.Errorf("复制 %s 失败: %w", f.Name, err)
+			}
+			continue
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("写入 zip 失败: %w", err)
+	}
+	return removedList, nil
+}
+
+type contentTypesDoc struct {
+	XMLName   xml.Name              `xml:"http://schemas.openxmlformats.org/package/2006/content-types Types"`
+	Defaults  []contentTypeDefault  `xml:"Default"`
+	Overrides []contentTypeOverride `xml:"Override"`
+}
+
+type contentTypeDefault struct {
+	Extension   string `xml:"Extension,attr"`
+	ContentType string `xml:"ContentType,attr"`
+}
+
+type contentTypeOverride struct {
+	PartName    string `xml:"PartName,attr"`
+	ContentType string `xml:"ContentType,attr"`
+}
+
+// stripContentTypes 删除被移除部件的 Override 声明
+func stripContentTypes(f *zip.File, removed map[string]bool) ([]byte, error) {
+	var doc contentTypesDoc
+	if err := decodePart(f, &doc); err != nil {
+		return nil, err
+	}
+	kept := doc.Overrides[:0]
+	for _, o := range doc.Overrides {
+		if !removed[strings.TrimPrefix(o.PartName, "/")] {
+			kept = append(kept, o)
+		}
+	}
+	doc.Overrides = kept
+	return marshalPart(&doc)
+}
+
+type relationshipsDoc struct {
+	XMLName       xml.Name           `xml:"http://schemas.openxmlformats.org/package/2006/relationships Relationships"`
+	Relationships []relationshipElem `xml:"Relationship"`
+}
+
+type relationshipElem struct {
+	ID         string `xml:"Id,attr"`
+	Type       string `xml:"Type,attr"`
+	Target     string `xml:"Target,attr"`
+	TargetMode string `xml:"TargetMode,attr,omitempty"`
+}
+
+// stripRelationships 删除 VBA 工程关系以及目标为被移除部件的关系
+func stripRelationships(f *zip.File, name string, removed map[string]bool) ([]byte, error) {
+	var doc relationshipsDoc
+	if err := decodePart(f, &doc); err != nil {
+		return nil, err
+	}
+
+	// 关系目标相对于源部件所在目录，如 word/_rels/document.xml.rels 的源目录为 word
+	sourceDir := path.Dir(path.Dir(name))
+	kept := doc.Relationships[:0]
+	for _, rel := range doc.Relationships {
+		if rel.Type == relTypeVBAProject {
+			continue
+		}
+		if !strings.EqualFold(rel.TargetMode, "External") {
+			target := rel.Target
+			if !strings.HasPrefix(target, "/") {
+				target = path.Join("/", sourceDir, target)
+			}
+			if removed[strings.TrimPrefix(path.Clean(target), "/")] {
+				continue
+			}
+		}
+		kept = append(kept, rel)
+	}
+	doc.Relationships = kept
+	return marshalPart(&doc)
+}
+
+func marshalPart(v interface{}) ([]byte, error) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header[:len(xml.Header)-1]), out...), nil
+}
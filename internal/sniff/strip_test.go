@@ -0,0 +1,139 @@
+package sniff
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readZipParts 读取 zip 包中全部部件的内容
+func readZipParts(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("读取 zip 失败: %v", err)
+	}
+	parts := make(map[string]string, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("打开 %s: %v", f.Name, err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("读取 %s: %v", f.Name, err)
+		}
+		parts[f.Name] = string(b)
+	}
+	return parts
+}
+
+func TestStripMacros(t *testing.T) {
+	const relsNS = "http://schemas.openxmlformats.org/package/2006/relationships"
+	const stylesRel = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"
+	input := buildZip(t,
+		zipFile{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="bin" ContentType="application/vnd.ms-office.vbaProject"/>` +
+			`<Override PartName="/word/document.xml" ContentType="` + docmMain + `"/>` +
+			`<Override PartName="/word/vbaData.xml" ContentType="application/vnd.ms-word.vbaData+xml"/>` +
+			`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+			`</Types>`},
+		zipFile{"_rels/.rels", `<Relationships xmlns="` + relsNS + `">` +
+			`<Relationship Id="rId1" Type="` + officeDocumentRel + `" Target="word/document.xml"/></Relationships>`},
+		zipFile{"word/document.xml", "<document/>"},
+		zipFile{"word/_rels/document.xml.rels", `<Relationships xmlns="` + relsNS + `">` +
+			`<Relationship Id="rId1" Type="` + relTypeVBAProject + `" Target="vbaProject.bin"/>` +
+			`<Relationship Id="rId2" Type="` + stylesRel + `" Target="styles.xml"/>` +
+			`<Relationship Id="rId3" Type="` + relTypeHyperlink + `" Target="https://example.com/vbaProject.bin" TargetMode="External"/>` +
+			`</Relationships>`},
+		zipFile{"word/vbaProject.bin", "\xd0\xcf\x11\xe0 vba"},
+		zipFile{"word/_rels/vbaProject.bin.rels", `<Relationships xmlns="` + relsNS + `">` +
+			`<Relationship Id="rId1" Type="http://schemas.microsoft.com/office/2006/relationships/wordVbaData" Target="vbaData.xml"/></Relationships>`},
+		zipFile{"word/vbaData.xml", "<wne:vbaSuppData/>"},
+		zipFile{"word/styles.xml", "<styles>原样保留</styles>"},
+	)
+
+	var out bytes.Buffer
+	removed, err := StripMacros(bytes.NewReader(input), int64(len(input)), &out)
+	if err != nil {
+		t.Fatalf("StripMacros: %v", err)
+	}
+	if want := []string{"word/vbaProject.bin", "word/_rels/vbaProject.bin.rels", "word/vbaData.xml"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("移除的部件 %q，期望 %q", removed, want)
+	}
+
+	stripped := out.Bytes()
+	parts := readZipParts(t, stripped)
+	var names []string
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"[Content_Types].xml", "_rels/.rels", "word/_rels/document.xml.rels", "word/document.xml", "word/styles.xml"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("剩余部件 %q，期望 %q", names, want)
+	}
+	if parts["word/styles.xml"] != "<styles>原样保留</styles>" {
+		t.Errorf("未修改的部件内容被改变: %q", parts["word/styles.xml"])
+	}
+
+	types := parts["[Content_Types].xml"]
+	if strings.Contains(types, "vbaData.xml") || !strings.Contains(types, "/word/styles.xml") || !strings.Contains(types, `Extension="bin"`) {
+		t.Errorf("[Content_Types].xml 处理结果不正确: %s", types)
+	}
+	rels := parts["word/_rels/document.xml.rels"]
+	if strings.Contains(rels, relTypeVBAProject) || !strings.Contains(rels, `Id="rId2"`) || !strings.Contains(rels, `Id="rId3"`) {
+		t.Errorf("关系处理结果不正确: %s", rels)
+	}
+
+	// 结果仍是主文档类型不变、不含宏的有效包
+	if _, err := CheckExtension("docm", bytes.NewReader(stripped), int64(len(stripped))); err != nil {
+		t.Errorf("移除宏后的包校验失败: %v", err)
+	}
+	found, err := InspectActiveContent(bytes.NewReader(stripped), int64(len(stripped)), KindOOXML)
+	if err != nil {
+		t.Fatalf("InspectActiveContent: %v", err)
+	}
+	if found.Any() {
+		t.Errorf("移除宏后仍检测到主动内容: %q", found.Details)
+	}
+}
+
+func TestStripMacrosWithoutMacros(t *testing.T) {
+	input := buildOOXML(t, "word/document.xml", docxMain, zipFile{"word/styles.xml", "<styles/>"})
+	var out bytes.Buffer
+	removed, err := StripMacros(bytes.NewReader(input), int64(len(input)), &out)
+	if err != nil {
+		t.Fatalf("StripMacros: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("不应移除任何部件: %q", removed)
+	}
+	if got, want := len(readZipParts(t, out.Bytes())), len(readZipParts(t, input)); got != want {
+		t.Errorf("部件数 %d，期望 %d", got, want)
+	}
+	if _, err := CheckExtension("docx", bytes.NewReader(out.Bytes()), int64(out.Len())); err != nil {
+		t.Errorf("处理后的包校验失败: %v", err)
+	}
+}
+
+func TestStripMacrosInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"not a zip":         []byte("plain text"),
+		"bad content types": buildZip(t, zipFile{"[Content_Types].xml", "<Types>"}, zipFile{"word/vbaProject.bin", "x"}),
+		"bad relationships": buildOOXML(t, "word/document.xml", docmMain, zipFile{"word/_rels/document.xml.rels", "<Relationships"}),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := StripMacros(bytes.NewReader(data), int64(len(data)), io.Discard); err == nil {
+				t.Fatal("期望返回错误")
+			}
+		})
+	}
+}
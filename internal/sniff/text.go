@@ -0,0 +1,75 @@
+package sniff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// detectText 根据文件开头判断文本类格式，含 NUL 或大量控制字符的内容视为二进制
+func detectText(head []byte) Kind {
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		head = head[len(utf8BOM):]
+	case bytes.HasPrefix(head, utf16LEBOM):
+		head = decodeUTF16(head[2:], binary.LittleEndian)
+	case bytes.HasPrefix(head, utf16BEBOM):
+		head = decodeUTF16(head[2:], binary.BigEndian)
+	}
+
+	if bytes.HasPrefix(head, []byte(`{\rtf`)) {
+		return KindRTF
+	}
+	if !looksLikeText(head) {
+		return KindUnknown
+	}
+
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	lower := strings.ToLower(string(trimmed[:min(len(trimmed), 1024)]))
+	switch {
+	case strings.Contains(lower, "mime-version:") && strings.Contains(lower, "multipart/"):
+		return KindMHTML
+	case strings.HasPrefix(lower, "<!doctype html"), strings.HasPrefix(lower, "<html"),
+		strings.HasPrefix(lower, "<") && (strings.Contains(lower, "<html") || strings.Contains(lower, "<body")):
+		return KindHTML
+	case strings.HasPrefix(lower, "<?xml"), strings.HasPrefix(lower, "<"):
+		return KindXML
+	}
+	return KindText
+}
+
+// looksLikeText 不允许 NUL，其余控制字符（制表、换行、换页、ESC 除外）不超过 1%
+func looksLikeText(b []byte) bool {
+	control := 0
+	for _, c := range b {
+		switch {
+		case c == 0:
+			return false
+		case c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != 0x1B:
+			control++
+		}
+	}
+	return control*100 <= len(b)
+}
+
+func decodeUTF16(b []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, order.Uint16(b[i:]))
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileStorage 本地磁盘存储驱动
+type FileStorage struct {
+	basePath string
+}
+
+var _ Storage = (*FileStorage)(nil)
+
+func NewStorage(basePath string) *FileStorage {
+	os.MkdirAll(basePath, 0755)
+	return &FileStorage{basePath: basePath}
+}
+
+// 暂存区与隔离区目录，位于存储根目录下
+const (
+	stagingDirName    = ".staging"
+	quarantineDirName = ".quarantine"
+	objectsDirName    = ".objects"
+)
+
+func (s *FileStorage) stagedPath(token string) string {
+	return filepath.Join(s.basePath, stagingDirName, token)
+}
+
+func (s *FileStorage) versionDir(fileID string, version int) string {
+	return filepath.Join(s.basePath, fileID, fmt.Sprintf("v%d", version))
+}
+
+// versionKey 返回版本内容的存储键 {fileID}/v{N}/{fileName}，即相对存储根目录的路径；
+// 文件名只取最后一段，防止写出版本目录
+func versionKey(fileID string, version int, fileName string) string {
+	name := path.Base(filepath.ToSlash(fileName))
+	if name == "." || name == "/" || name == ".." {
+		name = "content"
+	}
+	return fmt.Sprintf("%s/v%d/%s", fileID, version, name)
+}
+
+// keyPath 将存储键转换为存储根目录下的路径，拒绝越出根目录的键
+func (s *FileStorage) keyPath(key string) (string, error) {
+	cleaned := path.Clean(key)
+	if key == "" || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") || strings.Contains(key, `\`) {
+		return "", fmt.Errorf("无效的存储键: %q", key)
+	}
+	return filepath.Join(s.basePath, filepath.FromSlash(cleaned)), nil
+}
+
+// SaveFile 以原始文件名保存到版本目录，存储键为 {fileID}/v{N}/{fileName}
+func (s *FileStorage) SaveFile(fileID string, version int, fileName string, src io.Reader) error {
+	filePath, err := s.keyPath(versionKey(fileID, version, fileName))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	outFile, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer outFile.Close()
+
+	if _, err := io.Copy(outFile, src); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	return nil
+}
+
+// GetFile 按存储键打开存储根目录下的文件
+func (s *FileStorage) GetFile(key string) (io.ReadCloser, error) {
+	filePath, err := s.keyPath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat 按存储键获取文件元信息
+func (s *FileStorage) Stat(key string) (*ObjectInfo, error) {
+	filePath, err := s.keyPath(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// DeleteFile 删除整个版本目录
+func (s *FileStorage) DeleteFile(fileID string, version int) error {
+	if err := os.RemoveAll(s.versionDir(fileID, version)); err != nil {
+		return fmt.Errorf("删除版本目录失败: %w", err)
+	}
+	return nil
+}
+
+// ListVersions 扫描文件目录下的 v{N} 子目录
+func (s *FileStorage) ListVersions(fileID string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, fileID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取文件目录失败: %w", err)
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "v") {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "v"))
+		if err != nil || v <= 0 {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// ListAllVersions 扫描存储根目录下各文件的 v{N} 目录，跳过暂存区等以点开头的目录；
+// 修改时间取目录及其中文件的最新修改时间，目录中只有一个文件时 Key 为该文件的存储键
+func (s *FileStorage) ListAllVersions() ([]FileInfo, error) {
+	files, err := os.ReadDir(s.basePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取存储目录失败: %w", err)
+	}
+
+	var infos []FileInfo
+	for _, file := range files {
+		if !file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		versions, err := s.ListVersions(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			info, err := s.versionDirInfo(file.Name(), v)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, *info)
+		}
+	}
+	return infos, nil
+}
+
+func (s *FileStorage) versionDirInfo(fileID string, version int) (*FileInfo, error) {
+	dir := s.versionDir(fileID, version)
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	info := &FileInfo{FileID: fileID, Version: version, ModTime: dirInfo.ModTime()}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := 0
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if fi.Mode().IsRegular() {
+			files++
+			info.Key = fmt.Sprintf("%s/v%d/%s", fileID, version, entry.Name())
+		}
+		info.Size += fi.Size()
+		if fi.ModTime().After(info.ModTime) {
+			info.ModTime = fi.ModTime()
+		}
+	}
+	if files != 1 {
+		info.Key = ""
+	}
+	return info, nil
+}
+
+// PutStaged 写入 {basePath}/.staging/{token}
+func (s *FileStorage) PutStaged(token string, src io.Reader) (int64, error) {
+	stagedPath := s.stagedPath(token)
+	if err := os.MkdirAll(filepath.Dir(stagedPath), 0755); err != nil {
+		return 0, fmt.Errorf("创建暂存目录失败: %w", err)
+	}
+	outFile, err := os.Create(stagedPath)
+	if err != nil {
+		return 0, fmt.Errorf("创建暂存文件失败: %w", err)
+	}
+	defer outFile.Close()
+
+	n, err := io.Copy(outFile, src)
+	if err != nil {
+		return n, fmt.Errorf("写入暂存文件失败: %w", err)
+	}
+	return n, nil
+}
+
+// CommitStaged 通过重命名将暂存文件移入版本目录，存储键与 SaveFile 相同
+func (s *FileStorage) CommitStaged(token string, fileID string, version int, fileName string) error {
+	filePath, err := s.keyPath(versionKey(fileID, version, fileName))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.Rename(s.stagedPath(token), filePath); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("提交暂存文件失败: %w", err)
+	}
+	return nil
+}
+
+// CommitStagedObject 通过重命名将暂存文件移入对象目录
+func (s *FileStorage) CommitStagedObject(token string, key string) error {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return fmt.Errorf("创建对象目录失败: %w", err)
+	}
+	if err := os.Rename(s.stagedPath(token), objectPath); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("提交暂存文件失败: %w", err)
+	}
+	return nil
+}
+
+// Quarantine 写入 {basePath}/.quarantine/{key}
+func (s *FileStorage) Quarantine(key string, src io.Reader) error {
+	dir := filepath.Join(s.basePath, quarantineDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("创建隔离目录失败: %w", err)
+	}
+	outFile, err := os.OpenFile(filepath.Join(dir, key), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建隔离文件失败: %w", err)
+	}
+	defer outFile.Close()
+
+	if _, err := io.Copy(outFile, src); err != nil {
+		return fmt.Errorf("写入隔离文件失败: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStorage) DeleteStaged(token string) error {
+	if err := os.Remove(s.stagedPath(token)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除暂存文件失败: %w", err)
+	}
+	return nil
+}
+
+// objectPath 返回附件对象路径 {basePath}/.objects/{key}，拒绝包含路径分隔符的键
+func (s *FileStorage) objectPath(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("无效的对象键: %q", key)
+	}
+	return filepath.Join(s.basePath, objectsDirName, key), nil
+}
+
+// PutObject 先写入临时文件再重命名，避免读取到写了一半的对象
+func (s *FileStorage) PutObject(key string, src io.Reader) (int64, error) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return 0, fmt.Errorf("创建对象目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(objectPath), "."+key+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("创建对象文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, fmt.Errorf("写入对象文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), objectPath); err != nil {
+		return n, fmt.Errorf("保存对象文件失败: %w", err)
+	}
+	return n, nil
+}
+
+func (s *FileStorage) GetObject(key string) (io.ReadCloser, error) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(objectPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *FileStorage) DeleteObject(key string) error {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(objectPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除对象文件失败: %w", err)
+	}
+	return nil
+}
+
+// ListObjects 列出 {basePath}/.objects 下的对象，包括写入中断残留的临时文件
+func (s *FileStorage) ListObjects() ([]ObjectInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, objectsDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取对象目录失败: %w", err)
+	}
+
+	infos := make([]ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, ObjectInfo{Key: entry.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	return infos, nil
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage 内存存储驱动，进程退出后数据丢失，适用于测试和演示环境
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	files       map[string]map[int]*memoryEntry
+	staged      map[string][]byte
+	quarantined map[string][]byte
+	objects     map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+var _ Storage = (*MemoryStorage)(nil)
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files:       make(map[string]map[int]*memoryEntry),
+		staged:      make(map[string][]byte),
+		quarantined: make(map[string][]byte),
+		objects:     make(map[string]*memoryEntry),
+	}
+}
+
+// memoryVersionKey 版本内容的存储键 {fileID}/v{N}
+func memoryVersionKey(fileID string, version int) string {
+	return fmt.Sprintf("%s/v%d", fileID, version)
+}
+
+// SaveFile 保存版本内容，存储键为 {fileID}/v{N}
+func (s *MemoryStorage) SaveFile(fileID string, version int, fileName string, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files[fileID] == nil {
+		s.files[fileID] = make(map[int]*memoryEntry)
+	}
+	s.files[fileID][version] = &memoryEntry{name: fileName, data: data, modTime: time.Now()}
+	return nil
+}
+
+// GetFile 按存储键读取版本内容或附件对象
+func (s *MemoryStorage) GetFile(key string) (io.ReadCloser, error) {
+	entry, err := s.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(entry.data)), nil
+}
+
+func (s *MemoryStorage) Stat(key string) (*ObjectInfo, error) {
+	entry, err := s.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: int64(len(entry.data)), ModTime: entry.modTime}, nil
+}
+
+// lookup 按存储键查找内容：.objects/ 前缀为附件对象，其余为 {fileID}/v{N} 形式的版本内容
+func (s *MemoryStorage) lookup(key string) (*memoryEntry, error) {
+	if objectKey := strings.TrimPrefix(key, objectKeyPrefix); objectKey != key {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		entry, ok := s.objects[objectKey]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return entry, nil
+	}
+
+	i := strings.LastIndex(key, "/v")
+	if i <= 0 {
+		return nil, ErrNotFound
+	}
+	version, err := strconv.Atoi(key[i+2:])
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return s.entry(key[:i], version)
+}
+
+func (s *MemoryStorage) DeleteFile(fileID string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files[fileID], version)
+	if len(s.files[fileID]) == 0 {
+		delete(s.files, fileID)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) ListVersions(fileID string) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := make([]int, 0, len(s.files[fileID]))
+	for v := range s.files[fileID] {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func (s *MemoryStorage) ListAllVersions() ([]FileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var infos []FileInfo
+	for fileID, versions := range s.files {
+		for v, entry := range versions {
+			infos = append(infos, FileInfo{
+				FileID:  fileID,
+				Version: v,
+				Key:     memoryVersionKey(fileID, v),
+				Size:    int64(len(entry.data)),
+				ModTime: entry.modTime,
+			})
+		}
+	}
+	return infos, nil
+}
+
+func (s *MemoryStorage) PutStaged(token string, src io.Reader) (int64, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return int64(len(data)), fmt.Errorf("写入暂存数据失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staged[token] = data
+	return int64(len(data)), nil
+}
+
+func (s *MemoryStorage) CommitStaged(token string, fileID string, version int, fileName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.staged[token]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.staged, token)
+	if s.files[fileID] == nil {
+		s.files[fileID] = make(map[int]*memoryEntry)
+	}
+	s.files[fileID][version] = &memoryEntry{name: fileName, data: data, modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) CommitStagedObject(token string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.staged[token]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.staged, token)
+	s.objects[key] = &memoryEntry{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) DeleteStaged(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.staged, token)
+	return nil
+}
+
+func (s *MemoryStorage) Quarantine(key string, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("写入隔离数据失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quarantined[key] = data
+	return nil
+}
+
+func (s *MemoryStorage) PutObject(key string, src io.Reader) (int64, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return int64(len(data)), fmt.Errorf("写入对象失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = &memoryEntry{data: data, modTime: time.Now()}
+	return int64(len(data)), nil
+}
+
+func (s *MemoryStorage) GetObject(key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return memoryObject{bytes.NewReader(entry.data)}, nil
+}
+
+// memoryObject 可随机读取的对象内容，便于处理 Range 请求
+type memoryObject struct {
+	*bytes.Reader
+}
+
+func (memoryObject) Close() error { return nil }
+
+func (s *MemoryStorage) DeleteObject(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *MemoryStorage) ListObjects() ([]ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]ObjectInfo, 0, len(s.objects))
+	for key, entry := range s.objects {
+		infos = append(infos, ObjectInfo{Key: key, Size: int64(len(entry.data)), ModTime: entry.modTime})
+	}
+	return infos, nil
+}
+
+func (s *MemoryStorage) entry(fileID string, version int) (*memoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.files[fileID][version]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return entry, nil
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"weboffice/internal/config"
+)
+
+// 默认分片大小：未知长度的上传按该大小切分为 multipart 分片
+const defaultS3PartSize = 16 << 20
+
+// S3Storage S3 兼容对象存储驱动（AWS S3、MinIO 等），
+// 每个文件版本保存为 {prefix}{fileID}/v{N} 对象
+type S3Storage struct {
+	client   *minio.Client
+	bucket   string
+	prefix   string
+	partSize uint64
+}
+
+var _ Storage = (*S3Storage)(nil)
+
+// NewS3Storage 创建对象存储驱动并校验存储桶可用
+func NewS3Storage(cfg *config.S3Config) (*S3Storage, error) {
+	if cfg == nil || cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("对象存储配置缺少 endpoint 或 bucket")
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建对象存储客户端失败: %w", err)
+	}
+
+	exists, err := client.BucketExists(context.Background(), cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("检查存储桶失败: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("存储桶不存在: %s", cfg.Bucket)
+	}
+
+	partSize := cfg.PartSize
+	if partSize == 0 {
+		partSize = defaultS3PartSize
+	}
+	return &S3Storage{
+		client:   client,
+		bucket:   cfg.Bucket,
+		prefix:   cfg.Prefix,
+		partSize: partSize,
+	}, nil
+}
+
+// versionKey 版本内容的存储键 {fileID}/v{N}，对象键为前缀加存储键
+func (s *S3Storage) versionKey(fileID string, version int) string {
+	return fmt.Sprintf("%s/v%d", fileID, version)
+}
+
+func (s *S3Storage) objectKey(fileID string, version int) string {
+	return s.prefix + s.versionKey(fileID, version)
+}
+
+// SaveFile 以 multipart 方式流式写入对象，原始文件名保存在对象元数据中，存储键为 {fileID}/v{N}
+func (s *S3Storage) SaveFile(fileID string, version int, fileName string, src io.Reader) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.objectKey(fileID, version), src, -1,
+		minio.PutObjectOptions{
+			ContentType:  "application/octet-stream",
+			UserMetadata: map[string]string{"filename": url.PathEscape(fileName)},
+			PartSize:     s.partSize,
+		})
+	if err != nil {
+		return fmt.Errorf("写入对象失败: %w", err)
+	}
+	return nil
+}
+
+// GetFile 按存储键返回对象的流式读取器，内容按需从对象存储拉取
+func (s *S3Storage) GetFile(key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(context.Background(), s.bucket, s.prefix+key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	// GetObject 为惰性请求，先 Stat 以便及时返回不存在错误
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, mapS3Error(err)
+	}
+	return obj, nil
+}
+
+func (s *S3Storage) Stat(key string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(context.Background(), s.bucket, s.prefix+key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	return &ObjectInfo{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3Storage) DeleteFile(fileID string, version int) error {
+	err := s.client.RemoveObject(context.Background(), s.bucket, s.objectKey(fileID, version), minio.RemoveObjectOptions{})
+	if err != nil && !errors.Is(mapS3Error(err), ErrNotFound) {
+		return fmt.Errorf("删除对象失败: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) ListVersions(fileID string) ([]int, error) {
+	prefix := s.prefix + fileID + "/"
+	var versions []int
+	for obj := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("列出对象失败: %w", obj.Err)
+		}
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if !strings.HasPrefix(name, "v") {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimPrefix(name, "v"))
+		if err != nil || v <= 0 {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// ListAllVersions 列出 {prefix}{fileID}/v{N} 形式的对象，跳过暂存区等以点开头的前缀
+func (s *S3Storage) ListAllVersions() ([]FileInfo, error) {
+	var infos []FileInfo
+	for obj := range s.client.ListObjects(context.Background(), s.bucket,
+		minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("列出对象失败: %w", obj.Err)
+		}
+		name := strings.TrimPrefix(obj.Key, s.prefix)
+		fileID, versionName, ok := strings.Cut(name, "/")
+		if !ok || strings.HasPrefix(fileID, ".") || !strings.HasPrefix(versionName, "v") {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimPrefix(versionName, "v"))
+		if err != nil || v <= 0 {
+			continue
+		}
+		infos = append(infos, FileInfo{FileID: fileID, Version: v, Key: name, Size: obj.Size, ModTime: obj.LastModified})
+	}
+	return infos, nil
+}
+
+func (s *S3Storage) stagedKey(token string) string {
+	return s.prefix + ".staging/" + token
+}
+
+func (s *S3Storage) PutStaged(token string, src io.Reader) (int64, error) {
+	info, err := s.client.PutObject(context.Background(), s.bucket, s.stagedKey(token), src, -1,
+		minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+			PartSize:    s.partSize,
+		})
+	if err != nil {
+		return 0, fmt.Errorf("写入暂存对象失败: %w", err)
+	}
+	return info.Size, nil
+}
+
+// CommitStaged 服务端复制暂存对象到版本键后删除暂存对象，存储键为 {fileID}/v{N}
+func (s *S3Storage) CommitStaged(token string, fileID string, version int, fileName string) error {
+	ctx := context.Background()
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:          s.bucket,
+			Object:          s.objectKey(fileID, version),
+			UserMetadata:    map[string]string{"filename": url.PathEscape(fileName)},
+			ReplaceMetadata: true,
+		},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: s.stagedKey(token)})
+	if err != nil {
+		return mapS3Error(err)
+	}
+	return s.DeleteStaged(token)
+}
+
+// CommitStagedObject 服务端复制暂存对象到附件对象键后删除暂存对象
+func (s *S3Storage) CommitStagedObject(token string, key string) error {
+	_, err := s.client.CopyObject(context.Background(),
+		minio.CopyDestOptions{Bucket: s.bucket, Object: s.objectDataKey(key)},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: s.stagedKey(token)})
+	if err != nil {
+		return mapS3Error(err)
+	}
+	return s.DeleteStaged(token)
+}
+
+func (s *S3Storage) DeleteStaged(token string) error {
+	err := s.client.RemoveObject(context.Background(), s.bucket, s.stagedKey(token), minio.RemoveObjectOptions{})
+	if err != nil && !errors.Is(mapS3Error(err), ErrNotFound) {
+		return fmt.Errorf("删除暂存对象失败: %w", err)
+	}
+	return nil
+}
+
+// Quarantine 写入 {prefix}.quarantine/{key} 对象
+func (s *S3Storage) Quarantine(key string, src io.Reader) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.prefix+".quarantine/"+key, src, -1,
+		minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+			PartSize:    s.partSize,
+		})
+	if err != nil {
+		return fmt.Errorf("写入隔离对象失败: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) objectDataKey(key string) string {
+	return s.prefix + ObjectStorageKey(key)
+}
+
+// PutObject 写入 {prefix}.objects/{key} 对象
+func (s *S3Storage) PutObject(key string, src io.Reader) (int64, error) {
+	info, err := s.client.PutObject(context.Background(), s.bucket, s.objectDataKey(key), src, -1,
+		minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+			PartSize:    s.partSize,
+		})
+	if err != nil {
+		return 0, fmt.Errorf("写入附件对象失败: %w", err)
+	}
+	return info.Size, nil
+}
+
+func (s *S3Storage) GetObject(key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(context.Background(), s.bucket, s.objectDataKey(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, mapS3Error(err)
+	}
+	return obj, nil
+}
+
+func (s *S3Storage) DeleteObject(key string) error {
+	err := s.client.RemoveObject(context.Background(), s.bucket, s.objectDataKey(key), minio.RemoveObjectOptions{})
+	if err != nil && !errors.Is(mapS3Error(err), ErrNotFound) {
+		return fmt.Errorf("删除附件对象失败: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) ListObjects() ([]ObjectInfo, error) {
+	prefix := s.objectDataKey("")
+	var infos []ObjectInfo
+	for obj := range s.client.ListObjects(context.Background(), s.bucket,
+		minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("列出附件对象失败: %w", obj.Err)
+		}
+		infos = append(infos, ObjectInfo{
+			Key:     strings.TrimPrefix(obj.Key, prefix),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+	}
+	return infos, nil
+}
+
+// mapS3Error 将对象不存在的错误转换为 ErrNotFound
+func mapS3Error(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return ErrNotFound
+	}
+	return err
+}
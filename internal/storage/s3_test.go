@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"weboffice/internal/config"
+)
+
+// fakeS3 S3 协议的最小本地替身，按路径风格处理存储桶与对象请求，
+// 支持驱动用到的 multipart 上传、服务端复制、读取与删除
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	uploads map[string]*fakeUpload
+	nextID  int
+}
+
+type fakeObject struct {
+	data     []byte
+	metadata http.Header
+	modTime  time.Time
+}
+
+type fakeUpload struct {
+	key      string
+	metadata http.Header
+	parts    map[int][]byte
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string]fakeObject),
+		uploads: make(map[string]*fakeUpload),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+
+	switch {
+	case key == "":
+		// BucketExists
+		if r.Method != http.MethodHead {
+			writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+		}
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		uploadID := strconv.Itoa(f.nextID)
+		f.uploads[uploadID] = &fakeUpload{key: key, metadata: userMetadata(r.Header), parts: make(map[int][]byte)}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: bucket, Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		upload, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		data, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		upload.parts[partNumber] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, partNumber))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		upload, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		numbers := make([]int, 0, len(upload.parts))
+		for n := range upload.parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, upload.parts[n]...)
+		}
+		f.objects[upload.key] = fakeObject{data: data, metadata: upload.metadata, modTime: time.Now()}
+		delete(f.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: upload.key, ETag: `"complete"`})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		_, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+		obj, ok := f.objects[sourceKey]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+			obj.metadata = userMetadata(r.Header)
+		}
+		obj.modTime = time.Now()
+		f.objects[key] = obj
+		writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			LastModified time.Time
+			ETag         string
+		}{LastModified: obj.modTime.UTC(), ETag: `"copy"`})
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for name, values := range obj.metadata {
+			w.Header()[name] = values
+		}
+		w.Header().Set("ETag", `"object"`)
+		http.ServeContent(w, r, key, obj.modTime, bytes.NewReader(obj.data))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// object 返回已写入的对象，测试用于核对对象键与元数据
+func (f *fakeS3) object(key string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[key]
+	return obj, ok
+}
+
+// readS3Body 读取请求体，明文 HTTP 下客户端使用 aws-chunked 流式签名编码
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	reader := bufio.NewReader(r.Body)
+	var data bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func userMetadata(header http.Header) http.Header {
+	metadata := make(http.Header)
+	for name, values := range header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			metadata[name] = values
+		}
+	}
+	return metadata
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: code})
+}
+
+// newTestS3Storage 启动本地替身并创建指向它的驱动
+func newTestS3Storage(t *testing.T) (*S3Storage, *fakeS3) {
+	t.Helper()
+	fake := newFakeS3("weboffice")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage(&config.S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "weboffice",
+		Prefix:    "wo/",
+		AccessKey: "test",
+		SecretKey: "test-secret",
+		PathStyle: true,
+		PartSize:  5 << 20,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s, fake
+}
+
+func readAll(t *testing.T, s Storage, key string) []byte {
+	t.Helper()
+	reader, err := s.GetFile(key)
+	if err != nil {
+		t.Fatalf("GetFile(%q): %v", key, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("读取 %q: %v", key, err)
+	}
+	return data
+}
+
+func TestS3SaveFileAndGetFile(t *testing.T) {
+	s, fake := newTestS3Storage(t)
+
+	// 超过分片大小的内容走多个分片
+	large := bytes.Repeat([]byte("0123456789"), 600*1024)
+	for _, tc := range []struct {
+		name    string
+		fileID  string
+		content []byte
+	}{
+		{"small", "f1", []byte("测试文档内容")},
+		{"multipart", "f2", large},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := s.SaveFile(tc.fileID, 3, "报告 v3.docx", bytes.NewReader(tc.content)); err != nil {
+				t.Fatalf("SaveFile: %v", err)
+			}
+			key := tc.fileID + "/v3"
+			obj, ok := fake.object("wo/" + key)
+			if !ok {
+				t.Fatalf("对象 wo/%s 未写入", key)
+			}
+			if got := obj.metadata.Get("X-Amz-Meta-Filename"); got != url.PathEscape("报告 v3.docx") {
+				t.Errorf("文件名元数据为 %q", got)
+			}
+
+			if got := readAll(t, s, key); !bytes.Equal(got, tc.content) {
+				t.Errorf("GetFile 返回 %d 字节，期望 %d 字节", len(got), len(tc.content))
+			}
+			info, err := s.Stat(key)
+			if err != nil {
+				t.Fatalf("Stat: %v", err)
+			}
+			if info.Key != key || info.Size != int64(len(tc.content)) {
+				t.Errorf("Stat 返回 %+v", info)
+			}
+		})
+	}
+}
+
+func TestS3CommitStaged(t *testing.T) {
+	s, fake := newTestS3Storage(t)
+
+	content := []byte("staged content")
+	size, err := s.PutStaged("tok1", bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("PutStaged: %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("PutStaged 返回大小 %d，期望 %d", size, len(content))
+	}
+
+	if err := s.CommitStaged("tok1", "f1", 2, "a.docx"); err != nil {
+		t.Fatalf("CommitStaged: %v", err)
+	}
+	if got := readAll(t, s, "f1/v2"); !bytes.Equal(got, content) {
+		t.Errorf("GetFile 返回 %q", got)
+	}
+	obj, _ := fake.object("wo/f1/v2")
+	if got := obj.metadata.Get("X-Amz-Meta-Filename"); got != "a.docx" {
+		t.Errorf("文件名元数据为 %q", got)
+	}
+	if _, ok := fake.object("wo/.staging/tok1"); ok {
+		t.Error("提交后暂存对象未删除")
+	}
+}
+
+func TestS3NotFound(t *testing.T) {
+	s, _ := newTestS3Storage(t)
+
+	if _, err := s.GetFile("missing/v1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFile 不存在的对象返回 %v，期望 ErrNotFound", err)
+	}
+	if _, err := s.Stat("missing/v1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat 不存在的对象返回 %v，期望 ErrNotFound", err)
+	}
+	if _, err := s.GetObject("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetObject 不存在的对象返回 %v，期望 ErrNotFound", err)
+	}
+	if err := s.CommitStaged("missing", "f1", 1, "a.docx"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CommitStaged 不存在的暂存对象返回 %v，期望 ErrNotFound", err)
+	}
+	if err := s.DeleteFile("missing", 1); err != nil {
+		t.Errorf("DeleteFile 不存在的对象返回 %v", err)
+	}
+}
+
+func TestNewS3StorageMissingBucket(t *testing.T) {
+	server := httptest.NewServer(newFakeS3("other"))
+	defer server.Close()
+
+	_, err := NewS3Storage(&config.S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "weboffice",
+		PathStyle: true,
+	})
+	if err == nil {
+		t.Fatal("存储桶不存在时 NewS3Storage 应返回错误")
+	}
+}
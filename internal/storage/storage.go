@@ -1,45 +1,96 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"time"
+
+	"weboffice/internal/config"
 )
 
-type FileStorage struct {
-	basePath string
+// ErrNotFound 请求的文件版本在存储后端中不存在
+var ErrNotFound = errors.New("storage: 文件不存在")
+
+// FileInfo 存储后端中某个文件版本的元信息
+type FileInfo struct {
+	FileID  string
+	Version int
+	Key     string // 内容的存储键，无法唯一确定时为空
+	Size    int64
+	ModTime time.Time
 }
 
-func NewStorage(basePath string) *FileStorage {
-	os.MkdirAll(basePath, 0755)
-	return &FileStorage{basePath: basePath}
+// ObjectInfo 存储后端中某个附件对象的元信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
 }
 
-// 保存文件并返回存储路径// 修改SaveFile函数签名，增加fileName参数
-func (s *FileStorage) SaveFile(fileID string, version int, fileName string, src io.Reader) error {
-	// 生成带版本和文件名的路径
-	versionDir := filepath.Join(s.basePath, fileID, fmt.Sprintf("v%d", version))
-	if err := os.MkdirAll(versionDir, 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
-	}
+// objectKeyPrefix 附件对象（含按摘要寻址的 Blob）在各驱动中的存储键前缀
+const objectKeyPrefix = ".objects/"
 
-	// 使用原始文件名保存
-	filePath := filepath.Join(versionDir, fileName)
-	outFile, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("创建文件失败: %w", err)
-	}
-	defer outFile.Close()
+// ObjectStorageKey 返回附件对象 key 的存储键，可直接用于 GetFile
+func ObjectStorageKey(key string) string {
+	return objectKeyPrefix + key
+}
 
-	if _, err := io.Copy(outFile, src); err != nil {
-		return fmt.Errorf("写入文件失败: %w", err)
-	}
-	return nil
+// Storage 文件版本存储后端接口，各驱动（本地磁盘、对象存储、内存）均实现该接口。
+// 内容以存储键定位：读取时只使用版本清单中的存储键，按版本号保存的内容可经 ListAllVersions 查得存储键
+type Storage interface {
+	// SaveFile 保存指定文件版本的内容
+	SaveFile(fileID string, version int, fileName string, src io.Reader) error
+	// GetFile 按存储键获取内容读取流，不存在时返回 ErrNotFound
+	GetFile(key string) (io.ReadCloser, error)
+	// Stat 按存储键获取内容元信息，不存在时返回 ErrNotFound
+	Stat(key string) (*ObjectInfo, error)
+	// DeleteFile 删除指定文件版本的内容，版本不存在时不报错
+	DeleteFile(fileID string, version int) error
+	// ListVersions 列出文件在存储中已有的版本号（升序）
+	ListVersions(fileID string) ([]int, error)
+	// ListAllVersions 列出存储中全部按版本号保存的内容，供垃圾回收与数据库核对
+	ListAllVersions() ([]FileInfo, error)
+
+	// PutStaged 将上传内容写入暂存区，返回写入字节数
+	PutStaged(token string, src io.Reader) (int64, error)
+	// CommitStaged 将暂存内容提交为指定文件版本，成功后暂存内容不再存在
+	CommitStaged(token string, fileID string, version int, fileName string) error
+	// CommitStagedObject 将暂存内容提交为附件对象（如按摘要寻址的 Blob），成功后暂存内容不再存在
+	CommitStagedObject(token string, key string) error
+	// DeleteStaged 删除暂存内容，不存在时不报错
+	DeleteStaged(token string) error
+
+	// Quarantine 将未通过内容检查的上传写入隔离区，隔离内容不会被提交为版本或自动清理
+	Quarantine(key string, src io.Reader) error
+
+	// PutObject 写入附件对象，已存在时覆盖，返回写入字节数
+	PutObject(key string, src io.Reader) (int64, error)
+	// GetObject 获取附件对象的读取流，不存在时返回 ErrNotFound；各驱动返回的读取流均支持 Seek
+	GetObject(key string) (io.ReadCloser, error)
+	// DeleteObject 删除附件对象，不存在时不报错
+	DeleteObject(key string) error
+	// ListObjects 列出全部附件对象（含按摘要寻址的 Blob）
+	ListObjects() ([]ObjectInfo, error)
 }
 
-// 获取文件读取流
-func (s *FileStorage) GetFile(fileID string, version int) (io.ReadCloser, error) {
-	filePath := filepath.Join(s.basePath, fileID, fmt.Sprintf("v%d", version), "content")
-	return os.Open(filePath)
+// 存储驱动名称
+const (
+	DriverLocal  = "local"
+	DriverMemory = "memory"
+	DriverS3     = "s3"
+)
+
+// New 根据配置创建存储后端
+func New(cfg *config.AppConfig) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "", DriverLocal:
+		return NewStorage(cfg.StoragePath), nil
+	case DriverMemory:
+		return NewMemoryStorage(), nil
+	case DriverS3:
+		return NewS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("不支持的存储驱动: %s", cfg.StorageDriver)
+	}
 }
@@ -1,14 +1,18 @@
 package utils
 
 import (
+	"errors"
 	"fmt" // 新增导入
 	"mime"
-	"mime/multipart" // 新增导入
 	"path/filepath"
 	"strings"
+
+	"weboffice/internal/config"
 )
 
-var allowedExtensions = map[string][]string{
+// mimeExtensions MIME 类型与扩展名的对应关系，仅用于校验声明的 MIME 类型是否与扩展名一致，
+// 允许哪些扩展名由 AllowedFileTypes 配置决定
+var mimeExtensions = map[string][]string{
 	// 文字文档
 	"application/msword": {"doc", "dot"},
 	"application/vnd.ms-word.document.macroEnabled.12":                        {"docm"},
@@ -43,49 +47,116 @@ var allowedExtensions = map[string][]string{
 	"application/dps": {"dps", "dpt"},
 }
 
+// extensionMimes 扩展名到 MIME 类型的反向索引，由 mimeExtensions 生成
+var extensionMimes = make(map[string]string)
+
 func init() {
 	// 注册Office文档类型
 	mime.AddExtensionType(".docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
 	mime.AddExtensionType(".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
 	mime.AddExtensionType(".pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation")
+
+	for mimeType, exts := range mimeExtensions {
+		for _, ext := range exts {
+			extensionMimes[ext] = mimeType
+		}
+	}
 }
 
-// ValidateFileType 验证文件类型合法性
-func ValidateFileType(fileHeader *multipart.FileHeader) error {
-	// 获取MIME类型
-	mimeType := fileHeader.Header.Get("Content-Type")
-	if mimeType == "" {
-		// 通过扩展名推断类型
-		ext := filepath.Ext(fileHeader.Filename)
-		mimeType = mime.TypeByExtension(ext)
+// ContentTypeByName 按文件扩展名返回内容类型，未知扩展名为 application/octet-stream
+func ContentTypeByName(fileName string) string {
+	ext := normalizeExt(filepath.Ext(fileName))
+	if mimeType, ok := extensionMimes[ext]; ok {
+		return mimeType
+	}
+	if mimeType := mime.TypeByExtension("." + ext); ext != "" && mimeType != "" {
+		return mimeType
 	}
+	return "application/octet-stream"
+}
 
-	// 获取小写扩展名（不带点）
-	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileHeader.Filename), "."))
+// 文件类型校验失败原因
+var (
+	ErrFileTypeNotAllowed = errors.New("不支持的文件类型")
+	ErrFileTypeBlocked    = errors.New("该类型文件已被策略禁止")
+	ErrFileTooLarge       = errors.New("文件超过大小上限")
+)
 
-	// 双重校验机制
-	for mimePattern, allowedExts := range allowedExtensions {
-		// 允许主类型匹配（如 application/vnd.openxmlformats-officedocument.*）
-		if strings.HasPrefix(mimeType, mimePattern) {
-			for _, allowedExt := range allowedExts {
-				if ext == allowedExt {
-					return nil
-				}
+// fileTypeRule 单个扩展名对应的策略
+type fileTypeRule struct {
+	category string
+	blocked  bool
+	maxSize  int64
+}
+
+// FileTypeRules 由 AllowedFileTypes 配置构建的上传文件类型校验规则
+type FileTypeRules struct {
+	byExt map[string]fileTypeRule
+}
+
+// NewFileTypeRules 根据按类别配置的策略构建扩展名索引
+func NewFileTypeRules(policies config.FileTypePolicies) *FileTypeRules {
+	rules := &FileTypeRules{byExt: make(map[string]fileTypeRule)}
+	for category, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		blocked := make(map[string]bool, len(policy.BlockedExtensions))
+		for _, ext := range policy.BlockedExtensions {
+			blocked[normalizeExt(ext)] = true
+		}
+		for _, ext := range policy.Extensions {
+			ext = normalizeExt(ext)
+			rules.byExt[ext] = fileTypeRule{
+				category: category,
+				blocked:  blocked[ext],
+				maxSize:  policy.MaxSize,
 			}
 		}
 	}
+	return rules
+}
+
+func normalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
 
-	// 放宽校验逻辑：如果扩展名匹配但MIME类型未知也允许通过
-	if mimeType == "application/octet-stream" {
-		for _, allowedExts := range allowedExtensions {
-			for _, allowedExt := range allowedExts {
-				if ext == allowedExt {
-					return nil
-				}
+// ValidateFileName 根据文件名、声明的MIME类型和大小校验上传策略，返回文件所属类别
+func (r *FileTypeRules) ValidateFileName(fileName string, mimeType string, size int64) (string, error) {
+	// 获取小写扩展名（不带点）
+	ext := normalizeExt(filepath.Ext(fileName))
+
+	rule, ok := r.byExt[ext]
+	if !ok {
+		return "", fmt.Errorf("%w: 扩展名=%s", ErrFileTypeNotAllowed, ext)
+	}
+	if rule.blocked {
+		return rule.category, fmt.Errorf("%w: 类别=%s 扩展名=%s", ErrFileTypeBlocked, rule.category, ext)
+	}
+	if rule.maxSize > 0 && size > rule.maxSize {
+		return rule.category, fmt.Errorf("%w: 类别=%s 上限=%d 字节 实际=%d 字节",
+			ErrFileTooLarge, rule.category, rule.maxSize, size)
+	}
+
+	// 双重校验机制：声明了具体MIME类型时须与扩展名一致，未知或通用类型只按扩展名判断
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		return rule.category, nil
+	}
+	matched := false
+	for mimePattern, exts := range mimeExtensions {
+		// 允许主类型匹配（如 application/vnd.openxmlformats-officedocument.*）
+		if !strings.HasPrefix(mimeType, mimePattern) {
+			continue
+		}
+		matched = true
+		for _, known := range exts {
+			if ext == known {
+				return rule.category, nil
 			}
 		}
 	}
-
-	// 未匹配到允许的类型
-	return fmt.Errorf("不支持的文件类型: MIME类型=%s 扩展名=%s", mimeType, ext)
+	if matched {
+		return rule.category, fmt.Errorf("%w: MIME类型=%s 与扩展名=%s 不一致", ErrFileTypeNotAllowed, mimeType, ext)
+	}
+	return rule.category, nil
 }
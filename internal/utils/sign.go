@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SignParts 使用 HMAC-SHA256 对各字段（以换行拼接）签名，返回十六进制签名
+func SignParts(key string, parts ...string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyParts 以常量时间比较签名是否与各字段匹配
+func VerifyParts(key, signature string, parts ...string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hmac.Equal(mac.Sum(nil), expected)
+}